@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -9,12 +12,16 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 
+	"golang.org/x/sys/unix"
 	log "k8s.io/klog/v2"
+	mountutils "k8s.io/mount-utils"
 
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/obs"
-	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils"
 )
 
 const (
@@ -26,12 +33,44 @@ const (
 	cloud         = "cloud"
 	credential    = "credential"
 	defaultOpts   = "-o big_writes -o max_write=131072 -o use_ino"
+
+	// credentialRotation means the passwd file is kept for the lifetime of the mount
+	credentialRotation = "credentialRotation"
+
+	// mountTableFile persists the obsfs mounts started by the connector
+	mountTableFile = "/var/lib/csi/mount-table.json"
+	// mountPasswdDir keeps the passwd files of the mounts whose credentials are not rotated,
+	// which are used to re-establish the mounts.
+	mountPasswdDir = "/var/lib/csi/mounts"
 )
 
-type ResponseBody struct {
-	Data string
+// MountEntry is an obsfs mount started by the connector
+type MountEntry struct {
+	BucketName string
+	TargetPath string
+	Region     string
+	Cloud      string
+	MountFlags string
+	PasswdFile string
 }
 
+// MountTable is the obsfs mounts started by the connector, keyed by the target path
+type MountTable struct {
+	mutex   sync.Mutex
+	entries map[string]*MountEntry
+}
+
+var mountTable = &MountTable{entries: make(map[string]*MountEntry)}
+
+// allowLegacyToken accepts the commands of the legacy protocol, it's only required when a plugin which does not
+// support ProtocolVersionHMAC may be running on the node.
+var allowLegacyToken = flag.Bool("allow-legacy-token", false, "Accept the commands authenticated by the legacy token")
+
+// authenticator is created after the flags are parsed
+var authenticator *obs.Authenticator
+
+type peerCredKey struct{}
+
 type ActionHandler struct{}
 
 func (*ActionHandler) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
@@ -44,18 +83,31 @@ func (*ActionHandler) ServeHTTP(writer http.ResponseWriter, request *http.Reques
 		genResponse(writer, http.StatusBadRequest, "Request body is empty")
 		return
 	}
-	log.Infof("ServerHTTP Request body: %s", string(body))
+	if err := checkPeerCred(request.Context()); err != nil {
+		genResponse(writer, http.StatusForbidden, err.Error())
+		return
+	}
 	var commandRPC obs.CommandRPC
 	if err := json.Unmarshal(body, &commandRPC); err != nil {
 		genResponse(writer, http.StatusBadRequest, err.Error())
 		return
 	}
-	if err := checkRequestToken(commandRPC.Token, commandRPC.Parameters); err != nil {
+	log.Infof("ServerHTTP Request action: %s, version: %d", commandRPC.Action, commandRPC.Version)
+	if commandRPC.Action == obs.ActionVersion {
+		genResponse(writer, http.StatusOK, strconv.Itoa(obs.ProtocolVersionHMAC))
+		return
+	}
+	if err := authenticator.Authenticate(&commandRPC); err != nil {
 		genResponse(writer, http.StatusBadRequest, fmt.Sprintf("Failed to check token, err: %v", err.Error()))
 		return
 	}
+	if commandRPC.Version != obs.ProtocolVersionHMAC {
+		log.Warningf("Accepted the %s action of the legacy protocol, upgrade the plugin and turn off "+
+			"--allow-legacy-token", commandRPC.Action)
+	}
 
-	if commandRPC.Action == obs.ActionMount {
+	switch commandRPC.Action {
+	case obs.ActionMount:
 		if err := checkMountParameters(commandRPC.Parameters); err != nil {
 			genResponse(writer, http.StatusBadRequest, fmt.Sprintf("Failed to check mount action parameters, err: %v", err.Error()))
 			return
@@ -65,22 +117,84 @@ func (*ActionHandler) ServeHTTP(writer http.ResponseWriter, request *http.Reques
 			return
 		}
 		genResponse(writer, http.StatusOK, "success")
-		return
+	case obs.ActionRefreshCredential:
+		if err := checkCredentialFile(commandRPC.Parameters[credential]); err != nil {
+			genResponse(writer, http.StatusBadRequest, fmt.Sprintf("Failed to check refreshCredential action parameters, err: %v", err.Error()))
+			return
+		}
+		if err := refreshCredentialHandler(commandRPC.Parameters); err != nil {
+			genResponse(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		genResponse(writer, http.StatusOK, "success")
+	case obs.ActionUnmount:
+		if len(commandRPC.Parameters[targetPath]) == 0 {
+			genResponse(writer, http.StatusBadRequest, fmt.Sprintf("param %s cannot be empty", targetPath))
+			return
+		}
+		if err := unmountHandler(commandRPC.Parameters[targetPath]); err != nil {
+			genResponse(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+		genResponse(writer, http.StatusOK, "success")
+	case obs.ActionStatus:
+		if len(commandRPC.Parameters[targetPath]) == 0 {
+			genResponse(writer, http.StatusBadRequest, fmt.Sprintf("param %s cannot be empty", targetPath))
+			return
+		}
+		data, _ := json.Marshal(getMountStatus(commandRPC.Parameters[targetPath]))
+		genResponse(writer, http.StatusOK, string(data))
+	case obs.ActionList:
+		statuses := make([]obs.MountStatus, 0)
+		for _, entry := range mountTable.list() {
+			statuses = append(statuses, getMountStatus(entry.TargetPath))
+		}
+		data, _ := json.Marshal(statuses)
+		genResponse(writer, http.StatusOK, string(data))
+	default:
+		genResponse(writer, http.StatusBadRequest, fmt.Sprintf("Invalid action %s", commandRPC.Action))
+	}
+}
+
+// checkPeerCred only allows root to send the commands, the socket is accessible to root only as well
+func checkPeerCred(ctx context.Context) error {
+	cred, ok := ctx.Value(peerCredKey{}).(*unix.Ucred)
+	if !ok {
+		return fmt.Errorf("failed to get the credentials of the peer")
 	}
-	genResponse(writer, http.StatusBadRequest, fmt.Sprintf("Invalid action %s", commandRPC.Action))
+	if cred.Uid != 0 {
+		return fmt.Errorf("the peer %d of uid %d is not allowed", cred.Pid, cred.Uid)
+	}
+	return nil
 }
 
-func checkRequestToken(token string, parameters map[string]string) error {
-	ciphertext := utils.Sha256(parameters)
-	original, err := utils.DecryptAESCBC(obs.Secret, token)
+func getPeerCred(conn *net.UnixConn) (*unix.Ucred, error) {
+	raw, err := conn.SyscallConn()
 	if err != nil {
+		return nil, err
+	}
+	var cred *unix.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cred, credErr
+}
+
+// generateKey generates a new HMAC key when the connector starts, the plugins read it on each command
+func generateKey() error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
 		return err
 	}
-	if ciphertext != original {
-		log.Errorf("Invalid token: %s %s", ciphertext, original)
-		return fmt.Errorf("invalid token")
+	tempFile := obs.KeyFile + ".tmp"
+	if err := os.WriteFile(tempFile, []byte(hex.EncodeToString(key)), 0600); err != nil {
+		return err
 	}
-	return nil
+	return os.Rename(tempFile, obs.KeyFile)
 }
 
 func checkMountParameters(parameters map[string]string) error {
@@ -90,7 +204,13 @@ func checkMountParameters(parameters map[string]string) error {
 			return fmt.Errorf("param %s cannot be empty", k)
 		}
 	}
-	credentialFile := parameters[credential]
+	return checkCredentialFile(parameters[credential])
+}
+
+func checkCredentialFile(credentialFile string) error {
+	if len(credentialFile) == 0 {
+		return fmt.Errorf("param %s cannot be empty", credential)
+	}
 	if !strings.HasPrefix(credentialFile, credentialDir) {
 		return fmt.Errorf("credential file can only use DIR: %s， current: %s", credentialDir, credentialFile)
 	}
@@ -101,7 +221,7 @@ func checkMountParameters(parameters map[string]string) error {
 }
 
 func genResponse(writer http.ResponseWriter, statusCode int, data string) {
-	responseBody := ResponseBody{
+	responseBody := obs.ResponseBody{
 		Data: data,
 	}
 	response, _ := json.Marshal(responseBody)
@@ -117,7 +237,7 @@ func genResponse(writer http.ResponseWriter, statusCode int, data string) {
 
 func main() {
 	//nolint:errcheck
-	flag.CommandLine.Parse([]string{})
+	flag.CommandLine.Parse(os.Args[1:])
 
 	initObsfsUtil()
 	if checkFileExists(obs.SocketPath) {
@@ -126,13 +246,36 @@ func main() {
 		}
 	}
 
+	restoreMounts()
+	if err := generateKey(); err != nil {
+		log.Fatalf("Failed to generate key file: %s, err: %v", obs.KeyFile, err)
+	}
+	authenticator = obs.NewAuthenticator(obs.KeyFile, *allowLegacyToken)
+
 	listen, err := net.Listen("unix", obs.SocketPath)
 	if err != nil {
 		log.Fatalf("net.Listen failed. path: %s, err: %v", obs.SocketPath, err)
 		return
 	}
+	if err := os.Chmod(obs.SocketPath, 0600); err != nil {
+		log.Fatalf("Failed to change mode of path: %s, err: %v", obs.SocketPath, err)
+	}
 	log.Infof("Success start listener")
-	server := http.Server{Handler: &ActionHandler{}}
+	server := http.Server{
+		Handler: &ActionHandler{},
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			unixConn, ok := conn.(*net.UnixConn)
+			if !ok {
+				return ctx
+			}
+			cred, err := getPeerCred(unixConn)
+			if err != nil {
+				log.Errorf("Failed to get the credentials of the peer, err: %v", err)
+				return ctx
+			}
+			return context.WithValue(ctx, peerCredKey{}, cred)
+		},
+	}
 	if err := server.Serve(listen); err != nil {
 		log.Fatalf("Socket Listener failed to close, err: %v", err)
 	}
@@ -148,22 +291,54 @@ func checkFileExists(filename string) bool {
 
 func mountHandler(parameters map[string]string) error {
 	credentialFile := parameters[credential]
-	defer deleteCredential(credentialFile)
+	if parameters[credentialRotation] != "true" {
+		// keep the passwd file to re-establish the mount, until the mount is unmounted
+		passwdFile := filepath.Join(mountPasswdDir, filepath.Base(obs.GetPasswdFile(parameters[targetPath])))
+		if err := os.MkdirAll(mountPasswdDir, 0700); err != nil {
+			deleteCredential(credentialFile)
+			return fmt.Errorf("failed to make dir %s: %v", mountPasswdDir, err)
+		}
+		if err := os.Rename(credentialFile, passwdFile); err != nil {
+			deleteCredential(credentialFile)
+			return fmt.Errorf("failed to move credential file %s: %v", credentialFile, err)
+		}
+		credentialFile = passwdFile
+	}
+
+	entry := &MountEntry{
+		BucketName: parameters[bucketName],
+		TargetPath: parameters[targetPath],
+		Region:     parameters[region],
+		Cloud:      parameters[cloud],
+		MountFlags: parameters[mountFlags],
+		PasswdFile: credentialFile,
+	}
+	if err := runObsfs(entry); err != nil {
+		deleteCredential(credentialFile)
+		return err
+	}
+	if err := mountTable.add(entry); err != nil {
+		log.Errorf("failed to save the mount of %s to the mount table: %v", entry.TargetPath, err)
+	}
+	return nil
+}
+
+func runObsfs(entry *MountEntry) error {
 	obsName := "obs"
-	if parameters[cloud] == "prod-cloud-ocb.orange-business.com" {
+	if entry.Cloud == "prod-cloud-ocb.orange-business.com" {
 		obsName = "oss"
 	}
 
-	mountOpts := parameters[mountFlags]
+	mountOpts := entry.MountFlags
 	if mountOpts == "" {
 		mountOpts = defaultOpts
 	}
 	options := []string{
 		"obsfs",
-		parameters[bucketName],
-		parameters[targetPath],
-		fmt.Sprintf("-o url=%s.%s.%s", obsName, parameters[region], parameters[cloud]),
-		fmt.Sprintf("-o passwd_file=%s", credentialFile),
+		entry.BucketName,
+		entry.TargetPath,
+		fmt.Sprintf("-o url=%s.%s.%s", obsName, entry.Region, entry.Cloud),
+		fmt.Sprintf("-o passwd_file=%s", entry.PasswdFile),
 		mountOpts,
 	}
 
@@ -178,6 +353,207 @@ func mountHandler(parameters map[string]string) error {
 	return nil
 }
 
+// unmountHandler unmounts the target path, and removes it from the mount table.
+// The mount is detached lazily if it can not be unmounted, e.g. the obsfs process is dead.
+func unmountHandler(target string) error {
+	if getMountStatus(target).Mounted {
+		if out, err := exec.Command("umount", target).CombinedOutput(); err != nil {
+			log.Warningf("failed to unmount %s, output: %s, error: %v, try to detach it", target, string(out), err)
+			if out, err = exec.Command("umount", "-l", target).CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to unmount %s, output: %s, error: %v", target, string(out), err)
+			}
+		}
+	}
+	entry, err := mountTable.remove(target)
+	if err != nil {
+		return fmt.Errorf("failed to remove the mount of %s from the mount table: %v", target, err)
+	}
+	if entry != nil {
+		deleteCredential(entry.PasswdFile)
+	}
+	log.Infof("success to unmount %s", target)
+	return nil
+}
+
+// getMountStatus returns the status of the obsfs mount at the target path
+func getMountStatus(target string) obs.MountStatus {
+	mountStatus := obs.MountStatus{
+		TargetPath: target,
+		Pid:        findObsfsPid(target),
+	}
+	if entry := mountTable.get(target); entry != nil {
+		mountStatus.BucketName = entry.BucketName
+	}
+
+	mountPoints, err := mountutils.New("").List()
+	if err != nil {
+		log.Errorf("failed to list the mount points: %v", err)
+	}
+	for _, mp := range mountPoints {
+		if mp.Path == target {
+			mountStatus.Mounted = true
+			break
+		}
+	}
+	// stat fails with "transport endpoint is not connected" when the FUSE process is dead
+	_, err = os.Stat(target)
+	mountStatus.Connected = mountStatus.Mounted && mountStatus.Pid > 0 && err == nil
+	return mountStatus
+}
+
+// findObsfsPid returns the obsfs process which serves the target path, it's 0 if there is none
+func findObsfsPid(target string) int {
+	dirs, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0
+	}
+	for _, dir := range dirs {
+		pid, err := strconv.Atoi(dir.Name())
+		if err != nil {
+			continue
+		}
+		cmdline, err := os.ReadFile(filepath.Join("/proc", dir.Name(), "cmdline"))
+		if err != nil {
+			continue
+		}
+		args := strings.Split(string(cmdline), "\x00")
+		if len(args) < 3 || filepath.Base(args[0]) != "obsfs" {
+			continue
+		}
+		if args[2] == target {
+			return pid
+		}
+	}
+	return 0
+}
+
+// restoreMounts re-establishes the mounts in the mount table whose obsfs process is dead, e.g. the connector
+// is upgraded, and removes the mounts which do not exist anymore.
+func restoreMounts() {
+	if err := mountTable.load(); err != nil {
+		log.Errorf("failed to load the mount table: %v", err)
+		return
+	}
+	for _, entry := range mountTable.list() {
+		mountStatus := getMountStatus(entry.TargetPath)
+		if mountStatus.Connected {
+			continue
+		}
+		if !mountStatus.Mounted {
+			log.Infof("%s is not mounted, remove it from the mount table", entry.TargetPath)
+			if _, err := mountTable.remove(entry.TargetPath); err != nil {
+				log.Errorf("failed to remove the mount of %s from the mount table: %v", entry.TargetPath, err)
+			}
+			deleteCredential(entry.PasswdFile)
+			continue
+		}
+
+		log.Infof("the obsfs process of %s is dead, re-establish the mount", entry.TargetPath)
+		if out, err := exec.Command("umount", "-l", entry.TargetPath).CombinedOutput(); err != nil {
+			log.Errorf("failed to detach %s, output: %s, error: %v", entry.TargetPath, string(out), err)
+			continue
+		}
+		if err := runObsfs(entry); err != nil {
+			log.Errorf("failed to re-establish the mount of %s: %v", entry.TargetPath, err)
+		}
+	}
+}
+
+func (t *MountTable) load() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	content, err := os.ReadFile(mountTableFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(content, &t.entries)
+}
+
+// save writes the mount table atomically, the caller must hold the mutex
+func (t *MountTable) save() error {
+	content, err := json.Marshal(t.entries)
+	if err != nil {
+		return err
+	}
+	tempFile := mountTableFile + ".tmp"
+	if err = os.WriteFile(tempFile, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempFile, mountTableFile)
+}
+
+func (t *MountTable) add(entry *MountEntry) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.entries[entry.TargetPath] = entry
+	return t.save()
+}
+
+func (t *MountTable) remove(target string) (*MountEntry, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	entry, ok := t.entries[target]
+	if !ok {
+		return nil, nil
+	}
+	delete(t.entries, target)
+	return entry, t.save()
+}
+
+func (t *MountTable) get(target string) *MountEntry {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.entries[target]
+}
+
+func (t *MountTable) list() []*MountEntry {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	entries := make([]*MountEntry, 0, len(t.entries))
+	for _, entry := range t.entries {
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// refreshCredentialHandler replaces the passwd files of the mounts whose credentials are rotated,
+// obsfs reads the new access keys from the passwd files before the old ones expire.
+func refreshCredentialHandler(parameters map[string]string) error {
+	credentialFile := parameters[credential]
+	defer deleteCredential(credentialFile)
+	content, err := os.ReadFile(credentialFile)
+	if err != nil {
+		return fmt.Errorf("failed to read credential file %s: %v", credentialFile, err)
+	}
+
+	entries, err := os.ReadDir(obs.PasswdDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read dir %s: %v", obs.PasswdDir, err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		// replace the passwd file atomically, obsfs may read it at any time
+		passwdFile := filepath.Join(obs.PasswdDir, entry.Name())
+		tempFile := filepath.Join(obs.PasswdDir, "."+entry.Name())
+		if err := os.WriteFile(tempFile, content, 0600); err != nil {
+			return fmt.Errorf("failed to write passwd file %s: %v", tempFile, err)
+		}
+		if err := os.Rename(tempFile, passwdFile); err != nil {
+			return fmt.Errorf("failed to replace passwd file %s: %v", passwdFile, err)
+		}
+	}
+	log.Infof("success to refresh the credentials of %d mounts", len(entries))
+	return nil
+}
+
 func deleteCredential(credential string) {
 	if strings.HasPrefix(credential, credentialDir) {
 		if err := os.RemoveAll(credential); err != nil {
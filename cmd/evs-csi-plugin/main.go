@@ -38,6 +38,11 @@ var (
 	nodeID      string
 	cloudConfig string
 	cluster     string
+	nodeName    string
+
+	maxVolumesPerNode int64
+
+	enableObsPopulator bool
 )
 
 func init() {
@@ -88,6 +93,15 @@ func main() {
 
 	cmd.PersistentFlags().StringVar(&cluster, "cluster", "", "The identifier of the cluster that the plugin is running in.")
 
+	cmd.PersistentFlags().StringVar(&nodeName, "node-name", "", "The name of the kubernetes node, "+
+		"the label evs.csi.huaweicloud.com/max-volumes-per-node of the node overrides the maximum number of volumes.")
+	cmd.PersistentFlags().Int64Var(&maxVolumesPerNode, "max-volumes-per-node", 0, "The maximum number of volumes "+
+		"that can be attached to the node. Computed from the ECS flavor and the attached disks if it's 0.")
+
+	cmd.PersistentFlags().BoolVar(&enableObsPopulator, "enable-obs-populator", false, "Enable the populator "+
+		"of the volumes from OBS objects. The volumes are attached to the node that the plugin is running on "+
+		"to write the objects.")
+
 	logs.InitLogs()
 	defer logs.FlushLogs()
 
@@ -106,9 +120,15 @@ func handle() {
 	}
 
 	d := evs.NewDriver(cloud, endpoint, cluster, nodeID)
+	d.SetNodeLimits(nodeName, maxVolumesPerNode)
 
 	mount := mounts.GetMountProvider()
 	metadata := metadatas.GetMetadataProvider(metadatas.MetadataID)
 	d.SetupDriver(mount, metadata)
+	if enableObsPopulator {
+		if err = d.EnableObsPopulator(mount, metadata); err != nil {
+			klog.Fatalf("Failed to enable the OBS populator: %v", err)
+		}
+	}
 	d.Run()
 }
@@ -24,8 +24,10 @@ type CloudCredentials struct {
 		Insecure  bool   `gcfg:"insecure"`
 		AccessKey string `gcfg:"access-key"`
 		SecretKey string `gcfg:"secret-key"`
-		ProjectID string `gcfg:"project-id"`
-		Idc       bool   `gcfg:"idc"`
+		// SecurityToken is required by the temporary access keys
+		SecurityToken string `gcfg:"security-token"`
+		ProjectID     string `gcfg:"project-id"`
+		Idc           bool   `gcfg:"idc"`
 	}
 
 	Vpc struct {
@@ -34,6 +36,23 @@ type CloudCredentials struct {
 		SecurityGroupID string `gcfg:"security-group-id"`
 	}
 
+	Evs struct {
+		// DeleteProtection protects all the volumes provisioned by the driver from deletion
+		DeleteProtection bool `gcfg:"delete-protection"`
+		// DeleteProtectionPolicy is the action on the protected volumes when deleted, refuse or retain
+		DeleteProtectionPolicy string `gcfg:"delete-protection-policy"`
+	}
+
+	Obs struct {
+		// CredentialSource is where the access keys come from: static, agency or metadata
+		CredentialSource string `gcfg:"credential-source"`
+		// AgencyName and AgencyDomainName are the agency to assume when the credential source is agency
+		AgencyName       string `gcfg:"agency-name"`
+		AgencyDomainName string `gcfg:"agency-domain-name"`
+		// CredentialDuration is the validity period of the temporary access keys in seconds
+		CredentialDuration int `gcfg:"credential-duration"`
+	}
+
 	CloudClient *golangsdk.ProviderClient
 }
 
@@ -46,6 +65,21 @@ type serviceCatalog struct {
 	WithOutProjectID bool
 }
 
+const (
+	// CredentialSourceStatic uses the access keys in the cloud config
+	CredentialSourceStatic = "static"
+	// CredentialSourceAgency obtains the temporary access keys from IAM by assuming an agency
+	CredentialSourceAgency = "agency"
+	// CredentialSourceMetadata obtains the temporary access keys of the agency bound to the ECS
+	// from the metadata service
+	CredentialSourceMetadata = "metadata"
+
+	// DeleteProtectionPolicyRefuse refuses to delete the protected volumes
+	DeleteProtectionPolicyRefuse = "refuse"
+	// DeleteProtectionPolicyRetain retains the protected volumes instead of deleting them
+	DeleteProtectionPolicyRetain = "retain"
+)
+
 var allServiceCatalog = map[string]serviceCatalog{
 	"ecs": {
 		Name:    "ecs",
@@ -67,6 +101,34 @@ var allServiceCatalog = map[string]serviceCatalog{
 		Name:    "evs",
 		Version: "v2.1",
 	},
+	"evsV5": {
+		Name:    "evs",
+		Version: "v5",
+	},
+	"dssV1": {
+		Name:    "dss",
+		Version: "v1",
+	},
+	"cbrV3": {
+		Name:    "cbr",
+		Version: "v3",
+	},
+	"iamV30": {
+		Name:             "iam",
+		Version:          "v3.0",
+		Scope:            "global",
+		WithOutProjectID: true,
+	},
+	"imsV2": {
+		Name:             "ims",
+		Version:          "v2",
+		WithOutProjectID: true,
+	},
+	"kmsV1": {
+		Name:         "kms",
+		Version:      "v1.0",
+		ResourceBase: "kms",
+	},
 	"sfsV2": {
 		Name:    "sfs",
 		Version: "v2",
@@ -116,6 +178,15 @@ func newServiceClient(cc *CloudCredentials, catalogName, region string) (*golang
 }
 
 func (c *CloudCredentials) Validate() error {
+	policy := c.Evs.DeleteProtectionPolicy
+	if policy != DeleteProtectionPolicyRefuse && policy != DeleteProtectionPolicyRetain {
+		return fmt.Errorf("delete-protection-policy error, expected %s or %s, but got %s",
+			DeleteProtectionPolicyRefuse, DeleteProtectionPolicyRetain, policy)
+	}
+	// The temporary access keys are obtained from the metadata service later, there are no long-lived keys
+	if c.Obs.CredentialSource == CredentialSourceMetadata && c.Global.AccessKey == "" {
+		return nil
+	}
 	err := c.newCloudClient()
 	if err != nil {
 		return err
@@ -128,6 +199,7 @@ func (c *CloudCredentials) newCloudClient() error {
 		IdentityEndpoint: c.Global.AuthURL,
 		AccessKey:        c.Global.AccessKey,
 		SecretKey:        c.Global.SecretKey,
+		SecurityToken:    c.Global.SecurityToken,
 		ProjectId:        c.Global.ProjectID,
 		ProjectName:      c.Global.Region,
 	}
@@ -188,3 +260,39 @@ func (c *CloudCredentials) EvsV21Client() (*golangsdk.ServiceClient, error) {
 func (c *CloudCredentials) EvsV1Client() (*golangsdk.ServiceClient, error) {
 	return newServiceClient(c, "evsV1", c.Global.Region)
 }
+
+func (c *CloudCredentials) EvsV5Client() (*golangsdk.ServiceClient, error) {
+	return newServiceClient(c, "evsV5", c.Global.Region)
+}
+
+func (c *CloudCredentials) DssV1Client() (*golangsdk.ServiceClient, error) {
+	return newServiceClient(c, "dssV1", c.Global.Region)
+}
+
+func (c *CloudCredentials) CbrV3Client() (*golangsdk.ServiceClient, error) {
+	return newServiceClient(c, "cbrV3", c.Global.Region)
+}
+
+// CbrV3RegionClient returns the CBR client of another region, the projectID is the project of the region
+func (c *CloudCredentials) CbrV3RegionClient(region, projectID string) (*golangsdk.ServiceClient, error) {
+	client, err := newServiceClient(c, "cbrV3", region)
+	if err != nil {
+		return nil, err
+	}
+	client.ProviderClient.ProjectID = projectID
+	client.ProviderClient.AKSKAuthOptions.ProjectId = projectID
+	client.ResourceBase = fmt.Sprintf("%s%s/%s/", client.Endpoint, allServiceCatalog["cbrV3"].Version, projectID)
+	return client, nil
+}
+
+func (c *CloudCredentials) IamV30Client() (*golangsdk.ServiceClient, error) {
+	return newServiceClient(c, "iamV30", c.Global.Region)
+}
+
+func (c *CloudCredentials) ImsV2Client() (*golangsdk.ServiceClient, error) {
+	return newServiceClient(c, "imsV2", c.Global.Region)
+}
+
+func (c *CloudCredentials) KmsV1Client() (*golangsdk.ServiceClient, error) {
+	return newServiceClient(c, "kmsV1", c.Global.Region)
+}
@@ -66,4 +66,13 @@ func setDefaultConfig(cc *CloudCredentials) {
 	if cc.Global.AuthURL == "" {
 		cc.Global.AuthURL = fmt.Sprintf("https://iam.%s:443/v3/", cc.Global.Cloud)
 	}
+	if cc.Evs.DeleteProtectionPolicy == "" {
+		cc.Evs.DeleteProtectionPolicy = DeleteProtectionPolicyRefuse
+	}
+	if cc.Obs.CredentialSource == "" {
+		cc.Obs.CredentialSource = CredentialSourceStatic
+	}
+	if cc.Obs.CredentialDuration == 0 {
+		cc.Obs.CredentialDuration = 3600
+	}
 }
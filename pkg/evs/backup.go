@@ -0,0 +1,190 @@
+package evs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/chnsz/golangsdk/openstack/cbr/v3/backups"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+)
+
+const (
+	// snapshotTypeSnapshot creates the snapshots as EVS snapshots, which is the default
+	snapshotTypeSnapshot = "snapshot"
+	// snapshotTypeBackup creates the snapshots as CBR backups, which are stored out of the disks
+	snapshotTypeBackup = "backup"
+
+	// backupSnapshotIDPrefix is the prefix of the IDs of the snapshots backed by CBR backups,
+	// the IDs of EVS snapshots have no prefix.
+	backupSnapshotIDPrefix = "backup:"
+
+	// backupTimeLayout is the time layout of CBR backups, which has no time zone
+	backupTimeLayout = "2006-01-02T15:04:05.999999"
+)
+
+func getSnapshotType(parameters map[string]string) (string, error) {
+	snapshotType := parameters["snapshotType"]
+	switch snapshotType {
+	case "", snapshotTypeSnapshot:
+		return snapshotTypeSnapshot, nil
+	case snapshotTypeBackup:
+		if parameters["vaultId"] == "" {
+			return "", status.Error(codes.InvalidArgument, "vaultId is required when snapshotType is backup")
+		}
+		return snapshotTypeBackup, nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument,
+			"snapshotType error, expected snapshot or backup, but got %s", snapshotType)
+	}
+}
+
+// parseBackupSnapshotID returns the backup ID if the snapshot is backed by a CBR backup
+func parseBackupSnapshotID(snapshotID string) (string, bool) {
+	if strings.HasPrefix(snapshotID, backupSnapshotIDPrefix) {
+		return strings.TrimPrefix(snapshotID, backupSnapshotIDPrefix), true
+	}
+	return "", false
+}
+
+func createBackupSnapshot(credentials *config.CloudCredentials, name, volumeID, vaultID string) (
+	*csi.CreateSnapshotResponse, error) {
+	list, err := services.ListBackups(credentials, services.ListBackupsOpts{Name: name, VaultID: vaultID})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Backups) > 1 {
+		return nil, status.Error(codes.Internal, "Multiple backups reported by CBR with same name")
+	}
+	if len(list.Backups) == 1 {
+		backup := &list.Backups[0]
+		if backup.ResourceId != volumeID {
+			return nil, status.Error(codes.AlreadyExists, "CreateSnapshot same name with different volumeId")
+		}
+		log.Infof("Backup with name: %s | volumeID: %s already exist. detail: %v", name, volumeID, backup)
+		return buildBackupSnapshotResponse(backup)
+	}
+
+	if _, err = services.GetVolume(credentials, volumeID); err != nil {
+		return nil, err
+	}
+	// The backup is returned without waiting for it to be available,
+	// the external-snapshotter polls the status through ListSnapshots.
+	backupID, err := services.CreateBackup(credentials, vaultID, name, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	backup, err := services.GetBackup(credentials, backupID)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Successful create backup %s of volume %s in vault %s", backupID, volumeID, vaultID)
+	return buildBackupSnapshotResponse(backup)
+}
+
+func buildBackupSnapshotResponse(backup *backups.BackupResp) (*csi.CreateSnapshotResponse, error) {
+	if err := checkBackupFailed(backup); err != nil {
+		return nil, err
+	}
+	return &csi.CreateSnapshotResponse{Snapshot: buildBackupSnapshot(backup)}, nil
+}
+
+func buildBackupSnapshot(backup *backups.BackupResp) *csi.Snapshot {
+	createdAt, err := time.Parse(backupTimeLayout, backup.CreatedAt)
+	if err != nil {
+		createdAt, _ = time.Parse(time.RFC3339, backup.CreatedAt)
+	}
+	return &csi.Snapshot{
+		SnapshotId:     backupSnapshotIDPrefix + backup.ID,
+		SizeBytes:      int64(backup.ResourceSize * common.GbByteSize),
+		SourceVolumeId: backup.ResourceId,
+		CreationTime:   timestamppb.New(createdAt),
+		ReadyToUse:     backup.Status == services.BackupAvailableStatus,
+	}
+}
+
+// checkBackupFailed reports the backup in error status as a failed snapshot,
+// it will never become ready to use.
+func checkBackupFailed(backup *backups.BackupResp) error {
+	if backup.Status == services.BackupErrorStatus {
+		return status.Errorf(codes.FailedPrecondition, "Backup %s of volume %s is in %s status, "+
+			"the snapshot creation failed", backup.ID, backup.ResourceId, backup.Status)
+	}
+	return nil
+}
+
+func checkBackupAvailable(credentials *config.CloudCredentials, backupID string) error {
+	backup, err := services.GetBackup(credentials, backupID)
+	if err != nil {
+		return err
+	}
+	if err = checkBackupFailed(backup); err != nil {
+		return err
+	}
+	if backup.Status != services.BackupAvailableStatus {
+		return status.Errorf(codes.Unavailable, "Backup %s is not ready to use, status: %s",
+			backupID, backup.Status)
+	}
+	return nil
+}
+
+func deleteBackupSnapshot(credentials *config.CloudCredentials, backupID string) error {
+	if err := services.DeleteBackup(credentials, backupID); err != nil {
+		if common.IsNotFound(err) {
+			log.Infof("Backup %s is already deleted.", backupID)
+			return nil
+		}
+		return status.Errorf(codes.Internal, "Failed to delete backup: %v", err)
+	}
+	log.Infof("Successful delete backup %s", backupID)
+	return nil
+}
+
+func getBackupSnapshot(credentials *config.CloudCredentials, backupID string) (*csi.ListSnapshotsResponse, error) {
+	backup, err := services.GetBackup(credentials, backupID)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return &csi.ListSnapshotsResponse{}, nil
+		}
+		return nil, err
+	}
+	if err = checkBackupFailed(backup); err != nil {
+		return nil, err
+	}
+	return &csi.ListSnapshotsResponse{
+		Entries: []*csi.ListSnapshotsResponse_Entry{{Snapshot: buildBackupSnapshot(backup)}},
+	}, nil
+}
+
+// listBackupSnapshots appends the backups to the response, the next token of the backups is
+// prefixed by backupSnapshotIDPrefix to tell them apart from the EVS snapshots.
+func listBackupSnapshots(credentials *config.CloudCredentials, response *csi.ListSnapshotsResponse,
+	volumeID string, offset, limit int) error {
+	opts := services.ListBackupsOpts{
+		ResourceID: volumeID,
+		Offset:     offset,
+		Limit:      limit,
+	}
+	list, err := services.ListBackups(credentials, opts)
+	if err != nil {
+		return err
+	}
+
+	for i := range list.Backups {
+		response.Entries = append(response.Entries, &csi.ListSnapshotsResponse_Entry{
+			Snapshot: buildBackupSnapshot(&list.Backups[i]),
+		})
+	}
+	if currentOffset := offset + len(list.Backups); currentOffset < list.Count {
+		response.NextToken = backupSnapshotIDPrefix + strconv.Itoa(currentOffset)
+	}
+	return nil
+}
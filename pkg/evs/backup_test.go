@@ -0,0 +1,51 @@
+package evs
+
+import "testing"
+
+func TestParseBackupSnapshotID(t *testing.T) {
+	tests := []struct {
+		name        string
+		snapshotID  string
+		backupID    string
+		isBackup    bool
+		description string
+	}{
+		{
+			name:        "test1",
+			snapshotID:  "backup:2d4e6f0a-1c3b-4a5d-8e7f-9a0b1c2d3e4f",
+			backupID:    "2d4e6f0a-1c3b-4a5d-8e7f-9a0b1c2d3e4f",
+			isBackup:    true,
+			description: "the snapshot is backed by a CBR backup",
+		},
+		{
+			name:        "test2",
+			snapshotID:  "2d4e6f0a-1c3b-4a5d-8e7f-9a0b1c2d3e4f",
+			backupID:    "",
+			isBackup:    false,
+			description: "the EVS snapshot",
+		},
+		{
+			name:        "test3",
+			snapshotID:  "Backup:2d4e6f0a-1c3b-4a5d-8e7f-9a0b1c2d3e4f",
+			backupID:    "",
+			isBackup:    false,
+			description: "the prefix is case sensitive",
+		},
+		{
+			name:        "test4",
+			snapshotID:  "snapshot-backup:2d4e6f0a",
+			backupID:    "",
+			isBackup:    false,
+			description: "the prefix is not at the beginning",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			backupID, isBackup := parseBackupSnapshotID(testCase.snapshotID)
+			if backupID != testCase.backupID || isBackup != testCase.isBackup {
+				t.Errorf("expected: %s/%v, got: %s/%v", testCase.backupID, testCase.isBackup, backupID, isBackup)
+			}
+		})
+	}
+}
@@ -14,8 +14,23 @@ const (
 	DssIDKey = "dedicated_storage_id"
 	// CreateForVolumeIDKey in volume metadata
 	CreateForVolumeIDKey = "create_for_volume_id"
+	// DeleteProtectionKey in volume metadata, the volume is protected from deletion when it's true, refuse or retain
+	DeleteProtectionKey = "delete_protection"
 	// HwPassthroughKey in volume metadata
 	HwPassthroughKey = "hw:passthrough"
 	CmkIDKey         = "__system__cmkid"
 	EncryptedKey     = "__system__encrypted"
+
+	// PopulatedFromKey in volume metadata, the OBS object which the volume is populated from
+	PopulatedFromKey = "populated_from"
+
+	// ImageIDKey in StorageClass parameters, the volumes are created from the IMS image
+	ImageIDKey = "imageId"
+
+	// PvcNameTagKey in volume tags
+	PvcNameTagKey = "k8s-pvc-name"
+	// PvcNsTagKey in volume tags
+	PvcNsTagKey = "k8s-pvc-namespace"
+	// ClusterIDTagKey in volume tags
+	ClusterIDTagKey = "k8s-cluster-id"
 )
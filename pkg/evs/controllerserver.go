@@ -2,6 +2,7 @@ package evs
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
@@ -13,6 +14,7 @@ import (
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	log "k8s.io/klog/v2"
 
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
@@ -23,15 +25,24 @@ import (
 
 const (
 	defaultSizeGB = 10
+	// maxVolumeSizeGB is the maximum size of an EVS data disk
+	maxVolumeSizeGB = 32768
+	// maxSharedAttachments is the maximum number of servers that a shareable volume can be attached to
+	maxSharedAttachments = 16
+
+	// cloneSnapshotPrefix is the name prefix of the temporary snapshots used to clone volumes
+	cloneSnapshotPrefix = "clone"
 )
 
 type ControllerServer struct {
+	csi.UnimplementedControllerServer
+
 	Driver *EvsDriver
 }
 
 func (cs *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse,
 	error) {
-	log.Infof("CreateVolumeCompleted: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("CreateVolumeCompleted: called with args %v", protosanitizer.StripSecrets(req))
 	credentials := cs.Driver.cloudCredentials
 
 	volName := req.GetName()
@@ -46,6 +57,16 @@ func (cs *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolum
 	}
 
 	parameters := req.GetParameters()
+	multiattach, err := getMultiattach(parameters)
+	if err != nil {
+		return nil, err
+	}
+	for _, capability := range req.GetVolumeCapabilities() {
+		if err = validateVolumeCapability(capability, multiattach); err != nil {
+			return nil, err
+		}
+	}
+
 	volumeAz := parameters["availability"]
 	if len(volumeAz) == 0 {
 		// Check from Topology
@@ -56,24 +77,104 @@ func (cs *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolum
 	}
 
 	dssID := parameters["dssId"]
+	sourceVolID := getSourceVolumeID(req.GetVolumeContentSource())
+
+	// The file system creation options are passed to NodeStageVolume through the volume context
+	volumeContext, err := parseFsOptions(parameters)
+	if err != nil {
+		return nil, err
+	}
+	if dssID != "" {
+		volumeContext[DssIDKey] = dssID
+	}
+	if err = parseNodeEncryption(parameters, volumeContext); err != nil {
+		return nil, err
+	}
+	// The data key of LUKS is stored in the metadata of the source volume, which is not copied to the new volume
+	if isLuksEncrypted(volumeContext) && req.GetVolumeContentSource() != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Volume content source is not supported when %s is %s",
+			NodeEncryptionKey, nodeEncryptionLuks)
+	}
+	if err = parseIOThrottle(parameters, volumeContext); err != nil {
+		return nil, err
+	}
+	if err = parseScsiReservation(parameters, volumeContext, multiattach); err != nil {
+		return nil, err
+	}
+	if isScsiReservationEnabled(volumeContext) {
+		for _, capability := range req.GetVolumeCapabilities() {
+			if capability.GetBlock() == nil {
+				return nil, status.Errorf(codes.InvalidArgument, "%s is only supported for the volumes "+
+					"in Block mode", ScsiReservationKey)
+			}
+		}
+	}
+	restoreOpts, err := parseRestoreCopyOpts(credentials, parameters)
+	if err != nil {
+		return nil, err
+	}
+	deleteProtection, err := getDeleteProtection(parameters)
+	if err != nil {
+		return nil, err
+	}
 
 	// Check if there are any volumes with the same name
 	if vol, err := services.CheckVolumeExists(credentials, volName, sizeGB); err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	} else if vol != nil {
-		return buildCreateVolumeResponse(vol, dssID), nil
+		if sourceVolID != "" {
+			cleanupCloneSnapshot(credentials, volName)
+		}
+		if getSourceSnapshotID(req.GetVolumeContentSource()) != "" {
+			cleanupRestoreCopy(credentials, volName, restoreOpts)
+		}
+		return buildCreateVolumeResponse(vol, volumeContext, req.GetVolumeContentSource()), nil
+	}
+
+	// Check if snapshot exists, it's copied to the AZ or region of the volume when required
+	snapshotID := getSourceSnapshotID(req.GetVolumeContentSource())
+	if snapshotID != "" {
+		snapshotID, volumeAz, err = prepareSnapshotRestore(credentials, volName, snapshotID, volumeAz,
+			len(parameters["availability"]) > 0, req.GetAccessibilityRequirements(), restoreOpts)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Check if snapshot exists
-	snapshotID, err := checkSnapshotExists(credentials, req.GetVolumeContentSource())
+	volumeType := parameters["type"]
+	if sourceVolID != "" {
+		sourceVol, err := checkSourceVolumeExists(credentials, sourceVolID, sizeGB)
+		if err != nil {
+			return nil, err
+		}
+		volumeAz, err = getCloneAZ(volumeAz, len(parameters["availability"]) > 0, sourceVol,
+			req.GetAccessibilityRequirements())
+		if err != nil {
+			return nil, err
+		}
+		if volumeType == "" {
+			volumeType = sourceVol.VolumeType
+		}
+		// EVS does not support cloning a disk directly, restore the clone from a temporary snapshot
+		snapshotID, err = createCloneSnapshot(credentials, volName, sourceVol)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	imageID, err := checkImageExists(credentials, parameters[ImageIDKey], sizeGB, req.GetVolumeContentSource())
+	if err != nil {
+		return nil, err
+	}
+
+	volumeTags, err := cs.parseTags(parameters)
 	if err != nil {
 		return nil, err
 	}
 
 	iops := 0
 	throughput := 0
-	volumeType := parameters["type"]
-	if volumeType == "GPSSD2" || volumeType == "ESSD2" {
+	if isQoSVolumeType(volumeType) {
 		iops, throughput, err = getIoAndThrough(parameters)
 		if err != nil {
 			return nil, err
@@ -81,6 +182,14 @@ func (cs *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolum
 	}
 
 	metadata := cs.parseMetadata(req, snapshotID)
+	if deleteProtection != "" {
+		metadata[DeleteProtectionKey] = deleteProtection
+	}
+	// The snapshot backed by a CBR backup is restored from the backup
+	backupID, fromBackup := parseBackupSnapshotID(snapshotID)
+	if fromBackup {
+		snapshotID = ""
+	}
 	createOpts := &cloudvolumes.CreateOpts{
 		Volume: cloudvolumes.VolumeOpts{
 			Name:             volName,
@@ -88,9 +197,13 @@ func (cs *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolum
 			VolumeType:       volumeType,
 			AvailabilityZone: volumeAz,
 			SnapshotID:       snapshotID,
+			BackupID:         backupID,
+			ImageID:          imageID,
 			Metadata:         metadata,
+			Tags:             volumeTags,
 			IOPS:             iops,
 			Throughput:       throughput,
+			Multiattach:      multiattach,
 		},
 		Scheduler: &cloudvolumes.SchedulerOpts{
 			StorageID: dssID,
@@ -98,7 +211,8 @@ func (cs *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolum
 	}
 
 	volumeID := ""
-	if sizeGB < 10 {
+	// The cinder API does not support restoring from the backups
+	if sizeGB < 10 && backupID == "" {
 		volumeID, err = services.CreateCinderCompleted(credentials, createOpts)
 	} else {
 		volumeID, err = services.CreateVolumeCompleted(credentials, createOpts)
@@ -107,6 +221,13 @@ func (cs *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolum
 		return nil, status.Error(codes.Internal, err.Error())
 	}
 
+	if sourceVolID != "" {
+		cleanupCloneSnapshot(credentials, volName)
+	}
+	if getSourceSnapshotID(req.GetVolumeContentSource()) != "" {
+		cleanupRestoreCopy(credentials, volName, restoreOpts)
+	}
+
 	volume, err := services.GetVolume(credentials, volumeID)
 	if err != nil {
 		return nil, err
@@ -114,7 +235,12 @@ func (cs *ControllerServer) CreateVolume(_ context.Context, req *csi.CreateVolum
 	log.Infof("Successfully created volume %s in Availability Zone: %s of size %d GiB",
 		volume.ID, volume.AvailabilityZone, volume.Size)
 
-	return buildCreateVolumeResponse(volume, dssID), nil
+	return buildCreateVolumeResponse(volume, volumeContext, req.GetVolumeContentSource()), nil
+}
+
+// isQoSVolumeType returns whether the IOPS and throughput of the volume type can be provisioned
+func isQoSVolumeType(volumeType string) bool {
+	return volumeType == "GPSSD2" || volumeType == "ESSD2"
 }
 
 func getIoAndThrough(parameters map[string]string) (int, int, error) {
@@ -157,6 +283,9 @@ func (cs *ControllerServer) parseMetadata(req *csi.CreateVolumeRequest, snapshot
 		metadata[EncryptedKey] = "1"
 	}
 
+	if tagPvcInfo, _ := getTagPvcInfo(parameters); tagPvcInfo {
+		return metadata
+	}
 	for _, key := range []string{PvcNameTag, PvcNsTag, PvNameKey} {
 		if v, ok := parameters[key]; ok {
 			metadata[key] = v
@@ -165,6 +294,106 @@ func (cs *ControllerServer) parseMetadata(req *csi.CreateVolumeRequest, snapshot
 	return metadata
 }
 
+// parseTags builds the tags of the volume from the tags parameter, a comma separated list of key=value pairs.
+// The PVC name, namespace and the cluster ID are also added as tags when tagPvcInfo is true.
+func (cs *ControllerServer) parseTags(parameters map[string]string) (map[string]string, error) {
+	volumeTags := make(map[string]string)
+	if tagsStr := strings.TrimSpace(parameters["tags"]); tagsStr != "" {
+		for _, tag := range strings.Split(tagsStr, ",") {
+			kv := strings.SplitN(tag, "=", 2)
+			key := strings.TrimSpace(kv[0])
+			if len(kv) != 2 || key == "" {
+				return nil, status.Errorf(codes.InvalidArgument,
+					"tags error, expected a list of key=value pairs, but got %s", tagsStr)
+			}
+			volumeTags[key] = strings.TrimSpace(kv[1])
+		}
+	}
+
+	tagPvcInfo, err := getTagPvcInfo(parameters)
+	if err != nil {
+		return nil, err
+	}
+	if tagPvcInfo {
+		pvcInfo := map[string]string{
+			PvcNameTagKey:   parameters[PvcNameTag],
+			PvcNsTagKey:     parameters[PvcNsTag],
+			ClusterIDTagKey: cs.Driver.cluster,
+		}
+		for key, value := range pvcInfo {
+			if value != "" {
+				volumeTags[key] = value
+			}
+		}
+	}
+
+	if len(volumeTags) == 0 {
+		return nil, nil
+	}
+	return volumeTags, nil
+}
+
+// getTagPvcInfo parses the tagPvcInfo parameter, the PVC info is stored in the tags instead of the metadata
+// when it's true.
+func getTagPvcInfo(parameters map[string]string) (bool, error) {
+	v, ok := parameters["tagPvcInfo"]
+	if !ok || v == "" {
+		return false, nil
+	}
+	tagPvcInfo, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, status.Errorf(codes.InvalidArgument,
+			"tagPvcInfo error, expected a boolean, but got %s, error: %s", v, err)
+	}
+	return tagPvcInfo, nil
+}
+
+// getMultiattach parses the multiattach parameter, shareable is accepted as an alias
+func getMultiattach(parameters map[string]string) (bool, error) {
+	for _, key := range []string{"multiattach", "shareable"} {
+		v, ok := parameters[key]
+		if !ok || v == "" {
+			continue
+		}
+		multiattach, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, status.Errorf(codes.InvalidArgument,
+				"%s error, expected a boolean, but got %s, error: %s", key, v, err)
+		}
+		return multiattach, nil
+	}
+	return false, nil
+}
+
+// validateVolumeCapability validates the access type and the access mode of the capability
+func validateVolumeCapability(capability *csi.VolumeCapability, multiattach bool) error {
+	if capability.GetBlock() == nil && capability.GetMount() == nil {
+		return status.Error(codes.InvalidArgument,
+			"Validation failed, the access type of the volume capability must be block or mount")
+	}
+	return validateAccessMode(capability, multiattach)
+}
+
+// validateAccessMode checks the access mode of the capability. A volume can only be written by multiple nodes
+// when it's shareable and in block mode, since the common file systems would be corrupted by multiple writers.
+func validateAccessMode(capability *csi.VolumeCapability, multiattach bool) error {
+	mode := capability.GetAccessMode().GetMode()
+	switch mode {
+	case csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER:
+		return nil
+	case csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER:
+		if !multiattach || capability.GetBlock() == nil {
+			return status.Errorf(codes.InvalidArgument,
+				"Validation failed, access mode %s is only supported for shareable block volumes", mode)
+		}
+		return nil
+	default:
+		return status.Errorf(codes.InvalidArgument, "Validation failed, access mode %s is not supported", mode)
+	}
+}
+
 func createVolumeValidation(volumeName string, capabilities []*csi.VolumeCapability) error {
 	if len(volumeName) == 0 {
 		return status.Error(codes.InvalidArgument, "Validation failed, volume name cannot be empty")
@@ -175,26 +404,174 @@ func createVolumeValidation(volumeName string, capabilities []*csi.VolumeCapabil
 	return nil
 }
 
-func checkSnapshotExists(credentials *config.CloudCredentials, content *csi.VolumeContentSource) (string, error) {
-	snapshotID := ""
+// checkImageExists checks whether the volume can be created from the IMS image,
+// the image can not be used together with the content source.
+func checkImageExists(credentials *config.CloudCredentials, imageID string, sizeGB int,
+	content *csi.VolumeContentSource) (string, error) {
+	if imageID == "" {
+		return "", nil
+	}
+	if content != nil {
+		return "", status.Errorf(codes.InvalidArgument,
+			"Validation failed, %s cannot be specified together with the volume content source", ImageIDKey)
+	}
+
+	image, err := services.GetImage(credentials, imageID)
+	if err != nil {
+		return "", err
+	}
+	if image.Status != services.ImageActiveStatus {
+		return "", status.Errorf(codes.Unavailable, "Image %s is not ready to use, status: %s",
+			imageID, image.Status)
+	}
+	if sizeGB < image.MinDisk {
+		return "", status.Errorf(codes.OutOfRange, "Validation failed, the volume size %d GiB is less than "+
+			"the minimum disk size %d GiB of image %s", sizeGB, image.MinDisk, imageID)
+	}
+	return imageID, nil
+}
+
+func getSourceSnapshotID(content *csi.VolumeContentSource) string {
 	if content != nil && content.GetSnapshot() != nil {
-		snapshotID = content.GetSnapshot().GetSnapshotId()
-		_, err := services.GetSnapshot(credentials, snapshotID)
-		if err != nil {
-			if common.IsNotFound(err) {
-				return snapshotID,
-					status.Errorf(codes.NotFound, "Error, snapshot ID %s does not exist.", snapshotID)
+		return content.GetSnapshot().GetSnapshotId()
+	}
+	return ""
+}
+
+func getSourceVolumeID(content *csi.VolumeContentSource) string {
+	if content != nil && content.GetVolume() != nil {
+		return content.GetVolume().GetVolumeId()
+	}
+	return ""
+}
+
+func checkSourceVolumeExists(credentials *config.CloudCredentials, sourceVolID string, sizeGB int) (
+	*cloudvolumes.Volume, error) {
+	sourceVol, err := services.GetVolume(credentials, sourceVolID)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "Error, source volume ID %s does not exist.", sourceVolID)
+		}
+		return nil, status.Errorf(codes.Internal, "Failed to retrieve the source volume %s: %v", sourceVolID, err)
+	}
+	if sourceVol.Size > sizeGB {
+		return nil, status.Errorf(codes.OutOfRange,
+			"Error, the requested size %d GiB is less than the size %d GiB of source volume %s",
+			sizeGB, sourceVol.Size, sourceVolID)
+	}
+	return sourceVol, nil
+}
+
+// getCloneAZ returns the AZ of the clone, which must be the same as the source volume,
+// because EVS can only restore a snapshot in the AZ where its source volume is located.
+func getCloneAZ(volumeAz string, specified bool, sourceVol *cloudvolumes.Volume,
+	requirement *csi.TopologyRequirement) (string, error) {
+	sourceAz := sourceVol.AvailabilityZone
+	if volumeAz == "" || volumeAz == sourceAz {
+		return sourceAz, nil
+	}
+	if !specified && topologyContainsZone(requirement, sourceAz) {
+		log.Infof("The clone will be created in the AZ %s of source volume %s instead of %s",
+			sourceAz, sourceVol.ID, volumeAz)
+		return sourceAz, nil
+	}
+	return "", status.Errorf(codes.InvalidArgument,
+		"Error, the clone must be in the availability zone %s of source volume %s, but got %s",
+		sourceAz, sourceVol.ID, volumeAz)
+}
+
+func topologyContainsZone(requirement *csi.TopologyRequirement, zone string) bool {
+	if requirement == nil {
+		return true
+	}
+	if len(requirement.GetRequisite()) == 0 && len(requirement.GetPreferred()) == 0 {
+		return true
+	}
+	for _, topology := range requirement.GetRequisite() {
+		if topology.GetSegments()[topologyKey] == zone {
+			return true
+		}
+	}
+	for _, topology := range requirement.GetPreferred() {
+		if topology.GetSegments()[topologyKey] == zone {
+			return true
+		}
+	}
+	return false
+}
+
+func getCloneSnapshotName(volName string) string {
+	return fmt.Sprintf("%s-%s", cloneSnapshotPrefix, volName)
+}
+
+func createCloneSnapshot(credentials *config.CloudCredentials, volName string, sourceVol *cloudvolumes.Volume) (
+	string, error) {
+	sourceVolID := sourceVol.ID
+	name := getCloneSnapshotName(volName)
+	pageList, err := services.ListSnapshots(credentials, snapshots.ListOpts{Name: name})
+	if err != nil {
+		return "", err
+	}
+	for _, snap := range pageList.Snapshots {
+		if snap.VolumeID != sourceVolID {
+			continue
+		}
+		switch snap.Status {
+		case services.SnapshotAvailableStatus:
+			log.Infof("The temporary snapshot %s of source volume %s is available", snap.ID, sourceVolID)
+			return snap.ID, nil
+		case services.SnapshotErrorStatus:
+			// The failed snapshot is deleted, and created again when the request is retried
+			if err = services.DeleteSnapshot(credentials, snap.ID); err != nil {
+				return "", err
 			}
-			return snapshotID,
-				status.Errorf(codes.Internal, "Failed to retrieve the snapshot %s: %v", snapshotID, err)
+			return "", status.Errorf(codes.Unavailable, "The temporary snapshot %s of source volume %s is in "+
+				"%s status, deleted it to retry", snap.ID, sourceVolID, snap.Status)
+		default:
+			return "", status.Errorf(codes.Unavailable, "The temporary snapshot %s of source volume %s is %s",
+				snap.ID, sourceVolID, snap.Status)
+		}
+	}
+
+	// The clone is created when the request is retried after the snapshot is available
+	opts := &snapshots.CreateOpts{
+		VolumeID: sourceVolID,
+		Name:     name,
+		Force:    true,
+	}
+	snap, err := services.CreateSnapshot(credentials, opts)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to create the temporary snapshot of source volume %s: %v",
+			sourceVolID, err)
+	}
+	if err = services.CreateSnapshotTags(credentials, snap.ID, sourceVol.Tags); err != nil {
+		return "", err
+	}
+	return "", status.Errorf(codes.Unavailable, "Creating the temporary snapshot %s of source volume %s",
+		snap.ID, sourceVolID)
+}
+
+// cleanupCloneSnapshot deletes the temporary snapshots used to create the clone,
+// the failures are only logged and the deletion will be retried by the next CreateVolume call.
+func cleanupCloneSnapshot(credentials *config.CloudCredentials, volName string) {
+	name := getCloneSnapshotName(volName)
+	pageList, err := services.ListSnapshots(credentials, snapshots.ListOpts{Name: name})
+	if err != nil {
+		log.Warningf("Failed to query the temporary snapshot %s: %v", name, err)
+		return
+	}
+	for _, snap := range pageList.Snapshots {
+		if err = services.DeleteSnapshot(credentials, snap.ID); err != nil && !common.IsNotFound(err) {
+			log.Warningf("Failed to delete the temporary snapshot %s: %v", snap.ID, err)
+			continue
 		}
+		log.Infof("Successfully deleted the temporary snapshot %s", snap.ID)
 	}
-	return snapshotID, nil
 }
 
 func (cs *ControllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse,
 	error) {
-	log.Infof("DeleteVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("DeleteVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
@@ -202,7 +579,19 @@ func (cs *ControllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolum
 	}
 
 	credentials := cs.Driver.cloudCredentials
-	if err := services.DeleteVolume(credentials, volumeID); err != nil {
+	retained, err := protectVolumeDeletion(credentials, volumeID)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			log.Infof("Volume %s does not exist, skip deleting", volumeID)
+			return &csi.DeleteVolumeResponse{}, nil
+		}
+		return nil, err
+	}
+	if retained {
+		return &csi.DeleteVolumeResponse{}, nil
+	}
+
+	if err = services.DeleteVolume(credentials, volumeID); err != nil {
 		if common.IsNotFound(err) {
 			log.Infof("Volume %s does not exist, skip deleting", volumeID)
 			return &csi.DeleteVolumeResponse{}, nil
@@ -216,7 +605,7 @@ func (cs *ControllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolum
 
 func (cs *ControllerServer) ControllerGetVolume(_ context.Context, req *csi.ControllerGetVolumeRequest) (
 	*csi.ControllerGetVolumeResponse, error) {
-	log.Infof("ControllerGetVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ControllerGetVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
@@ -229,7 +618,9 @@ func (cs *ControllerServer) ControllerGetVolume(_ context.Context, req *csi.Cont
 		return nil, err
 	}
 
-	volStatus := &csi.ControllerGetVolumeResponse_VolumeStatus{}
+	volStatus := &csi.ControllerGetVolumeResponse_VolumeStatus{
+		VolumeCondition: getVolumeCondition(volume),
+	}
 	for _, attachment := range volume.Attachments {
 		volStatus.PublishedNodeIds = append(volStatus.PublishedNodeIds, attachment.ServerID)
 	}
@@ -259,7 +650,7 @@ const (
 
 func (cs *ControllerServer) ControllerPublishVolume(_ context.Context, req *csi.ControllerPublishVolumeRequest) (
 	*csi.ControllerPublishVolumeResponse, error) {
-	log.Infof("ControllerPublishVolume: called with args %+v", protosanitizer.StripSecrets(*req))
+	log.Infof("ControllerPublishVolume: called with args %+v", protosanitizer.StripSecrets(req))
 	credentials := cs.Driver.cloudCredentials
 	instanceID := req.GetNodeId()
 	volumeID := req.GetVolumeId()
@@ -271,10 +662,18 @@ func (cs *ControllerServer) ControllerPublishVolume(_ context.Context, req *csi.
 		return nil, err
 	}
 
+	if err = validateAccessMode(req.GetVolumeCapability(), volume.Multiattach); err != nil {
+		return nil, err
+	}
+
 	attachmentStatus := volumeAttachmentStatus(volume, instanceID)
-	log.Infof("ControllerPublishVolume: attachmentStatus is %s", attachmentStatus)
+	log.Infof("ControllerPublishVolume: attachmentStatus is %v", attachmentStatus)
 	switch attachmentStatus {
 	case VolumeNotAttached:
+		if volume.Multiattach && len(volume.Attachments) >= maxSharedAttachments {
+			return nil, status.Errorf(codes.ResourceExhausted,
+				"Error, the shareable volume %s has been attached to %d servers", volumeID, len(volume.Attachments))
+		}
 		if err := services.AttachVolumeCompleted(credentials, instanceID, volumeID); err != nil {
 			return nil, status.Errorf(codes.Internal, "Failed to publish volume %s to ECS %s with error %v",
 				volumeID, instanceID, err)
@@ -342,6 +741,10 @@ func volumeAttachmentStatus(volume *cloudvolumes.Volume, instanceID string) Volu
 		return VolumeAttachedCurrentServer
 	}
 	if services.EvsInUseStatus == volumeStatus && !attachment {
+		// the shareable volume can be attached to several servers at the same time
+		if volume.Multiattach {
+			return VolumeNotAttached
+		}
 		return VolumeAttachedOtherServer
 	}
 	return VolumeAttachError
@@ -367,7 +770,7 @@ func publishValidation(cc *config.CloudCredentials, volumeID, instanceID string,
 
 func (cs *ControllerServer) ControllerUnpublishVolume(_ context.Context, req *csi.ControllerUnpublishVolumeRequest) (
 	*csi.ControllerUnpublishVolumeResponse, error) {
-	log.Infof("ControllerUnpublishVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ControllerUnpublishVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	credentials := cs.Driver.cloudCredentials
 	instanceID := req.GetNodeId()
@@ -375,24 +778,22 @@ func (cs *ControllerServer) ControllerUnpublishVolume(_ context.Context, req *cs
 
 	volume, err := unpublishValidation(credentials, volumeID, instanceID)
 	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			log.Warningf("Warning, the volume %s does not exist, skip unpublishing", volumeID)
+			return &csi.ControllerUnpublishVolumeResponse{}, nil
+		}
 		return nil, err
 	}
 
-	if volume.Status == services.EvsAvailableStatus || len(volume.Attachments) == 0 {
+	attachment := getServerAttachment(volume, instanceID)
+	if attachment == nil {
 		log.Warningf("Warning, the volume %s is not in the server %s attach volume list, skip unpublishing",
 			volumeID, instanceID)
 		return &csi.ControllerUnpublishVolumeResponse{}, nil
 	}
 
-	err = services.DetachVolumeCompleted(credentials, instanceID, volumeID)
-	if err != nil {
-		if strings.Contains(err.Error(), "Ecs.0111") {
-			log.Warningf("Warning, the volume %s is not in the server %s attach volume list, skip unpublishing",
-				volumeID, instanceID)
-			return &csi.ControllerUnpublishVolumeResponse{}, nil
-		}
-		return nil, status.Errorf(codes.Internal, "Error unpublishing volume %s from server %s, error: %v",
-			volumeID, instanceID, err)
+	if err = detachVolume(credentials, instanceID, volume, attachment); err != nil {
+		return nil, err
 	}
 
 	log.Infof("Successfully unpublished, volume ID: %s", volumeID)
@@ -407,21 +808,13 @@ func unpublishValidation(cc *config.CloudCredentials, volumeID, instanceID strin
 		return nil, status.Error(codes.InvalidArgument, "Validation failed, ECS instance ID cannot be empty")
 	}
 
-	volume, err := services.GetVolume(cc, volumeID)
-	if err != nil {
-		return nil, err
-	}
-
-	if _, err = services.GetServer(cc, instanceID); err != nil {
-		return nil, err
-	}
-
-	return volume, nil
+	// The ECS instance is not required to exist, the volume is forcibly detached from the deleted instance
+	return services.GetVolume(cc, volumeID)
 }
 
 func (cs *ControllerServer) ListVolumes(_ context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse,
 	error) {
-	log.Infof("ListVolumes: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ListVolumes: called with args %v", protosanitizer.StripSecrets(req))
 
 	if req.MaxEntries < 0 {
 		return nil, status.Error(codes.InvalidArgument,
@@ -439,7 +832,7 @@ func (cs *ControllerServer) ListVolumes(_ context.Context, req *csi.ListVolumesR
 
 	entries := make([]*csi.ListVolumesResponse_Entry, 0, len(volumes))
 	for _, vol := range volumes {
-		publishedNodeIds := make([]string, len(vol.Attachments))
+		publishedNodeIds := make([]string, 0, len(vol.Attachments))
 		for _, attachment := range vol.Attachments {
 			publishedNodeIds = append(publishedNodeIds, attachment.ServerID)
 		}
@@ -451,6 +844,7 @@ func (cs *ControllerServer) ListVolumes(_ context.Context, req *csi.ListVolumesR
 			},
 			Status: &csi.ListVolumesResponse_VolumeStatus{
 				PublishedNodeIds: publishedNodeIds,
+				VolumeCondition:  getVolumeCondition(&vol),
 			},
 		}
 
@@ -471,7 +865,7 @@ func (cs *ControllerServer) ListVolumes(_ context.Context, req *csi.ListVolumesR
 
 func (cs *ControllerServer) CreateSnapshot(_ context.Context, req *csi.CreateSnapshotRequest) (
 	*csi.CreateSnapshotResponse, error) {
-	log.Infof("CreateSnapshot called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("CreateSnapshot called with request %v", protosanitizer.StripSecrets(req))
 
 	credentials := cs.Driver.cloudCredentials
 	name := req.GetName()
@@ -480,21 +874,49 @@ func (cs *ControllerServer) CreateSnapshot(_ context.Context, req *csi.CreateSna
 		return nil, status.Errorf(codes.Internal, "Failed to check create snapshot param, %v", err)
 	}
 
+	snapshotType, err := getSnapshotType(req.GetParameters())
+	if err != nil {
+		return nil, err
+	}
+	if snapshotType == snapshotTypeBackup {
+		return createBackupSnapshot(credentials, name, volumeID, req.GetParameters()["vaultId"])
+	}
+
 	response, err := checkDuplicateSnapshotName(credentials, name, volumeID)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to check duplicate snapshot name: %v", err)
+		return nil, err
+	}
+	volume, err := services.GetVolume(credentials, volumeID)
+	if err != nil {
+		return nil, err
 	}
 	if response != nil {
 		log.Infof("Snapshot with name: %s | volumeID: %s already exist. detail: %v", name, volumeID, response)
+		// The previous request may fail after the snapshot is created, the tags are set again
+		snapshotID := response.GetSnapshot().GetSnapshotId()
+		if err = services.CreateSnapshotTags(credentials, snapshotID, volume.Tags); err != nil {
+			return nil, err
+		}
 		return response, nil
 	}
 
-	if _, err := services.GetVolume(credentials, volumeID); err != nil {
-		return nil, err
+	// The snapshot is returned without waiting for it to be available,
+	// the external-snapshotter polls the status through ListSnapshots.
+	opts := &snapshots.CreateOpts{
+		VolumeID: volumeID,
+		Name:     name,
+		Force:    true,
 	}
-	snapshot, err := services.CreateSnapshotCompleted(credentials, name, volumeID)
+	snapshot, err := services.CreateSnapshot(credentials, opts)
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "Failed to create snapshot to completed: %v", err)
+		return nil, status.Errorf(codes.Internal, "Failed to create snapshot: %v", err)
+	}
+	// The snapshot carries the same tags as the source volume
+	if err = services.CreateSnapshotTags(credentials, snapshot.ID, volume.Tags); err != nil {
+		return nil, err
+	}
+	if err = checkSnapshotFailed(snapshot); err != nil {
+		return nil, err
 	}
 	log.Infof("Successful create snapshot. detail: %v", protosanitizer.StripSecrets(snapshot))
 	return buildSnapshotResponse(snapshot), nil
@@ -502,16 +924,30 @@ func (cs *ControllerServer) CreateSnapshot(_ context.Context, req *csi.CreateSna
 
 func buildSnapshotResponse(snap *snapshots.Snapshot) *csi.CreateSnapshotResponse {
 	return &csi.CreateSnapshotResponse{
-		Snapshot: &csi.Snapshot{
-			SnapshotId:     snap.ID,
-			SizeBytes:      int64(snap.Size * common.GbByteSize),
-			SourceVolumeId: snap.VolumeID,
-			CreationTime:   timestamppb.New(snap.CreatedAt),
-			ReadyToUse:     true,
-		},
+		Snapshot: buildSnapshot(snap),
 	}
 }
 
+func buildSnapshot(snap *snapshots.Snapshot) *csi.Snapshot {
+	return &csi.Snapshot{
+		SnapshotId:     snap.ID,
+		SizeBytes:      int64(snap.Size * common.GbByteSize),
+		SourceVolumeId: snap.VolumeID,
+		CreationTime:   timestamppb.New(snap.CreatedAt),
+		ReadyToUse:     snap.Status == services.SnapshotAvailableStatus,
+	}
+}
+
+// checkSnapshotFailed reports the snapshot in error status as a failed snapshot,
+// it will never become ready to use.
+func checkSnapshotFailed(snap *snapshots.Snapshot) error {
+	if snap.Status == services.SnapshotErrorStatus {
+		return status.Errorf(codes.FailedPrecondition, "Snapshot %s of volume %s is in %s status, "+
+			"the snapshot creation failed", snap.ID, snap.VolumeID, snap.Status)
+	}
+	return nil
+}
+
 func createSnapshotValidation(name string, volumeID string) error {
 	if volumeID == "" {
 		return status.Error(codes.InvalidArgument, "CreateSnapshot volumeID cannot be empty")
@@ -537,6 +973,9 @@ func checkDuplicateSnapshotName(credentials *config.CloudCredentials, name strin
 		if snap.VolumeID != volumeID {
 			return nil, status.Error(codes.AlreadyExists, "CreateSnapshot same name with different volumeId")
 		}
+		if err = checkSnapshotFailed(snap); err != nil {
+			return nil, err
+		}
 		return buildSnapshotResponse(snap), nil
 	}
 	if len(listSnapshots) > 1 {
@@ -547,12 +986,18 @@ func checkDuplicateSnapshotName(credentials *config.CloudCredentials, name strin
 
 func (cs *ControllerServer) DeleteSnapshot(_ context.Context, req *csi.DeleteSnapshotRequest) (
 	*csi.DeleteSnapshotResponse, error) {
-	log.Infof("DeleteSnapshot called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("DeleteSnapshot called with request %v", protosanitizer.StripSecrets(req))
 	credentials := cs.Driver.cloudCredentials
 	id := req.GetSnapshotId()
 	if id == "" {
 		return nil, status.Error(codes.InvalidArgument, "Snapshot ID must be provided in DeleteSnapshot request")
 	}
+	if backupID, ok := parseBackupSnapshotID(id); ok {
+		if err := deleteBackupSnapshot(credentials, backupID); err != nil {
+			return nil, err
+		}
+		return &csi.DeleteSnapshotResponse{}, nil
+	}
 
 	if err := services.DeleteSnapshot(credentials, id); err != nil {
 		if common.IsNotFound(err) {
@@ -567,17 +1012,34 @@ func (cs *ControllerServer) DeleteSnapshot(_ context.Context, req *csi.DeleteSna
 
 func (cs *ControllerServer) ListSnapshots(_ context.Context, req *csi.ListSnapshotsRequest) (
 	*csi.ListSnapshotsResponse, error) {
-	log.Infof("ListSnapshots called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ListSnapshots called with request %v", protosanitizer.StripSecrets(req))
 	credentials := cs.Driver.cloudCredentials
 
-	availableStatus := "available"
+	if backupID, ok := parseBackupSnapshotID(req.GetSnapshotId()); ok {
+		return getBackupSnapshot(credentials, backupID)
+	}
+
+	// The EVS snapshots are listed first, followed by the CBR backups
+	response := &csi.ListSnapshotsResponse{}
+	limit := int(req.MaxEntries)
+	if backupToken, ok := parseBackupSnapshotID(req.GetStartingToken()); ok {
+		offset, err := strconv.Atoi(backupToken)
+		if err != nil {
+			return nil, status.Errorf(codes.Aborted, "Invalid starting token %s", req.GetStartingToken())
+		}
+		if err = listBackupSnapshots(credentials, response, req.GetSourceVolumeId(), offset, limit); err != nil {
+			return nil, err
+		}
+		log.Infof("Successful query snapshot list. detail: %v", protosanitizer.StripSecrets(response))
+		return response, nil
+	}
+
 	opts := snapshots.ListOpts{}
 	if req.GetSnapshotId() != "" {
 		opts.ID = req.GetSnapshotId()
 	} else {
 		opts.VolumeID = req.GetSourceVolumeId()
-		opts.Status = availableStatus // Only retrieve snapshots available
-		opts.Limit = int(req.MaxEntries)
+		opts.Limit = limit
 		offset, err := strconv.Atoi(req.GetStartingToken())
 		if err != nil {
 			offset = 0
@@ -589,29 +1051,37 @@ func (cs *ControllerServer) ListSnapshots(_ context.Context, req *csi.ListSnapsh
 		return nil, err
 	}
 
-	var responses []*csi.ListSnapshotsResponse_Entry
-	for _, element := range pageList.Snapshots {
-		responses = append(responses, generateListSnapshotsResponseEntry(element))
+	for i, element := range pageList.Snapshots {
+		if opts.ID != "" {
+			if err = checkSnapshotFailed(&pageList.Snapshots[i]); err != nil {
+				return nil, err
+			}
+		}
+		response.Entries = append(response.Entries, generateListSnapshotsResponseEntry(element))
 	}
-	response := &csi.ListSnapshotsResponse{Entries: responses}
-	currentOffset := opts.Offset + len(responses)
+	currentOffset := opts.Offset + len(response.Entries)
 	if currentOffset < pageList.Count {
 		response.NextToken = strconv.Itoa(currentOffset)
+	} else if opts.ID == "" {
+		// All the EVS snapshots are listed, continue with the CBR backups
+		if limit > 0 && len(response.Entries) >= limit {
+			response.NextToken = backupSnapshotIDPrefix + "0"
+		} else {
+			if limit > 0 {
+				limit -= len(response.Entries)
+			}
+			if err = listBackupSnapshots(credentials, response, opts.VolumeID, 0, limit); err != nil {
+				return nil, err
+			}
+		}
 	}
 	log.Infof("Successful query snapshot list. detail: %v", protosanitizer.StripSecrets(response))
 	return response, nil
 }
 
 func generateListSnapshotsResponseEntry(snapshot snapshots.Snapshot) *csi.ListSnapshotsResponse_Entry {
-	snapshotEntry := csi.Snapshot{
-		SizeBytes:      int64(snapshot.Size * common.GbByteSize),
-		SnapshotId:     snapshot.ID,
-		SourceVolumeId: snapshot.VolumeID,
-		CreationTime:   timestamppb.New(snapshot.CreatedAt),
-		ReadyToUse:     true,
-	}
 	return &csi.ListSnapshotsResponse_Entry{
-		Snapshot: &snapshotEntry,
+		Snapshot: buildSnapshot(&snapshot),
 	}
 }
 
@@ -626,7 +1096,7 @@ func (cs *ControllerServer) ControllerGetCapabilities(_ context.Context, _ *csi.
 
 func (cs *ControllerServer) ValidateVolumeCapabilities(_ context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (
 	*csi.ValidateVolumeCapabilitiesResponse, error) {
-	log.Infof("ValidateVolumeCapabilities: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ValidateVolumeCapabilities: called with args %v", protosanitizer.StripSecrets(req))
 
 	volCapabilities := req.GetVolumeCapabilities()
 	volumeID := req.GetVolumeId()
@@ -637,35 +1107,87 @@ func (cs *ControllerServer) ValidateVolumeCapabilities(_ context.Context, req *c
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Validation failed, volume ID cannot be empty")
 	}
-	if _, err := services.GetVolume(cs.Driver.cloudCredentials, volumeID); err != nil {
+	volume, err := services.GetVolume(cs.Driver.cloudCredentials, volumeID)
+	if err != nil {
 		return nil, err
 	}
 
 	for _, capability := range volCapabilities {
-		if capability.GetAccessMode().GetMode() != cs.Driver.vcap[0].Mode {
-			return &csi.ValidateVolumeCapabilitiesResponse{Message: "Requested volume capability not supported"}, nil
+		if err = validateVolumeCapability(capability, volume.Multiattach); err != nil {
+			return &csi.ValidateVolumeCapabilitiesResponse{Message: err.Error()}, nil
 		}
 	}
 
 	return &csi.ValidateVolumeCapabilitiesResponse{
 		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
-			VolumeCapabilities: []*csi.VolumeCapability{
-				{
-					AccessMode: cs.Driver.vcap[0],
-				},
-			},
+			VolumeCapabilities: volCapabilities,
 		},
 	}, nil
 }
 
-func (cs *ControllerServer) GetCapacity(_ context.Context, _ *csi.GetCapacityRequest) (
+// GetCapacity returns the capacity that can be used to create the volumes in the availability zone.
+// The EVS quotas are shared by all the availability zones of the project, so the capacity is exact only when it's
+// known per zone, i.e. the volumes are created in the storage pool of dssId or in the availability zone of
+// the availability parameter. Otherwise, the project-wide quota is reported for every zone, which is an upper bound
+// of the capacity of each zone, and the sum of the capacities of all the zones is more than the quota.
+func (cs *ControllerServer) GetCapacity(_ context.Context, req *csi.GetCapacityRequest) (
 	*csi.GetCapacityResponse, error) {
-	return nil, status.Error(codes.Unimplemented, "GetCapacity is not yet implemented")
+	log.Infof("GetCapacity: called with args %v", protosanitizer.StripSecrets(req))
+
+	credentials := cs.Driver.cloudCredentials
+	parameters := req.GetParameters()
+	zone := req.GetAccessibleTopology().GetSegments()[topologyKey]
+
+	if volumeAz := parameters["availability"]; zone != "" && volumeAz != "" && volumeAz != zone {
+		log.Infof("The volumes can only be created in %s, no capacity in %s", volumeAz, zone)
+		return buildCapacityResponse(0), nil
+	}
+
+	availableGB, err := services.GetAvailableGigabytes(credentials, parameters["type"])
+	if err != nil {
+		return nil, err
+	}
+
+	if dssID := parameters["dssId"]; dssID != "" {
+		pool, err := services.GetStoragePool(credentials, dssID)
+		if err != nil {
+			return nil, err
+		}
+		if zone != "" && pool.AvailabilityZone != zone {
+			log.Infof("The dedicated storage pool %s is located in %s, no capacity in %s",
+				dssID, pool.AvailabilityZone, zone)
+			return buildCapacityResponse(0), nil
+		}
+		if availableGB == services.UnlimitedQuota || pool.Available() < availableGB {
+			availableGB = pool.Available()
+		}
+	}
+
+	response := buildCapacityResponse(availableGB)
+	log.Infof("Successfully obtained capacity: %v", protosanitizer.StripSecrets(response))
+	return response, nil
+}
+
+func buildCapacityResponse(availableGB int) *csi.GetCapacityResponse {
+	availableBytes := int64(math.MaxInt64)
+	if availableGB != services.UnlimitedQuota {
+		availableBytes = int64(availableGB) * common.GbByteSize
+	}
+
+	maxVolumeBytes := int64(maxVolumeSizeGB) * common.GbByteSize
+	if availableBytes < maxVolumeBytes {
+		maxVolumeBytes = availableBytes
+	}
+
+	return &csi.GetCapacityResponse{
+		AvailableCapacity: availableBytes,
+		MaximumVolumeSize: wrapperspb.Int64(maxVolumeBytes),
+	}
 }
 
 func (cs *ControllerServer) ControllerExpandVolume(_ context.Context, req *csi.ControllerExpandVolumeRequest) (
 	*csi.ControllerExpandVolumeResponse, error) {
-	log.Infof("ControllerExpandVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ControllerExpandVolume: called with args %v", protosanitizer.StripSecrets(req))
 	cc := cs.Driver.cloudCredentials
 
 	volumeID := req.GetVolumeId()
@@ -711,6 +1233,76 @@ func (cs *ControllerServer) ControllerExpandVolume(_ context.Context, req *csi.C
 	}, nil
 }
 
+func (cs *ControllerServer) ControllerModifyVolume(_ context.Context, req *csi.ControllerModifyVolumeRequest) (
+	*csi.ControllerModifyVolumeResponse, error) {
+	log.Infof("ControllerModifyVolume: called with args %v", protosanitizer.StripSecrets(req))
+	cc := cs.Driver.cloudCredentials
+
+	volumeID := req.GetVolumeId()
+	parameters := req.GetMutableParameters()
+	if err := modifyVolumeValidation(volumeID, parameters); err != nil {
+		return nil, err
+	}
+
+	iops, throughput, err := getIoAndThrough(parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	volume, err := services.GetVolume(cc, volumeID)
+	if err != nil {
+		return nil, err
+	}
+
+	if newType := parameters["type"]; newType != "" && newType != volume.VolumeType {
+		opts := services.RetypeOpts{NewType: newType}
+		if isQoSVolumeType(newType) {
+			opts.IOPS = iops
+			opts.Throughput = throughput
+		} else if iops > 0 || throughput > 0 {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"Validation failed, IOPS and throughput cannot be specified for volume type %s", newType)
+		}
+
+		if err = services.RetypeVolume(cc, volumeID, opts); err != nil {
+			return nil, err
+		}
+		log.Infof("Successfully changed the type of volume %s from %s to %s", volumeID, volume.VolumeType, newType)
+		return &csi.ControllerModifyVolumeResponse{}, nil
+	}
+
+	if (iops == 0 || iops == volume.IOPS.TotalVal) && (throughput == 0 || throughput == volume.Throughput.TotalVal) {
+		log.Infof("The volume %s does not need to be modified", volumeID)
+		return &csi.ControllerModifyVolumeResponse{}, nil
+	}
+	if !isQoSVolumeType(volume.VolumeType) {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"Validation failed, IOPS and throughput cannot be modified for volume type %s", volume.VolumeType)
+	}
+
+	opts := services.QoSOpts{IOPS: iops, Throughput: throughput}
+	if err = services.ModifyVolumeQoS(cc, volumeID, opts); err != nil {
+		return nil, err
+	}
+	log.Infof("Successfully modified the IOPS and throughput of volume %s to %d and %d", volumeID, iops, throughput)
+	return &csi.ControllerModifyVolumeResponse{}, nil
+}
+
+func modifyVolumeValidation(volumeID string, parameters map[string]string) error {
+	if len(volumeID) == 0 {
+		return status.Error(codes.InvalidArgument, "Validation failed, volume ID cannot be empty")
+	}
+	if len(parameters) == 0 {
+		return status.Error(codes.InvalidArgument, "Validation failed, mutable parameters cannot be empty")
+	}
+	for key := range parameters {
+		if key != "type" && key != "iops" && key != "throughput" {
+			return status.Errorf(codes.InvalidArgument, "Validation failed, parameter %s cannot be modified", key)
+		}
+	}
+	return nil
+}
+
 func getAZFromTopology(requirement *csi.TopologyRequirement) string {
 	for _, topology := range requirement.GetPreferred() {
 		zone, exists := topology.GetSegments()[topologyKey]
@@ -728,26 +1320,26 @@ func getAZFromTopology(requirement *csi.TopologyRequirement) string {
 	return ""
 }
 
-func buildCreateVolumeResponse(vol *cloudvolumes.Volume, dssID string) *csi.CreateVolumeResponse {
+func buildCreateVolumeResponse(vol *cloudvolumes.Volume, volumeContext map[string]string,
+	content *csi.VolumeContentSource) *csi.CreateVolumeResponse {
 	accessibleTopology := []*csi.Topology{
 		{
 			Segments: map[string]string{topologyKey: vol.AvailabilityZone},
 		},
 	}
 
-	VolumeContext := make(map[string]string)
-	if dssID != "" {
-		VolumeContext[DssIDKey] = dssID
-	}
-
 	response := &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
 			VolumeId:           vol.ID,
 			CapacityBytes:      int64(vol.Size * common.GbByteSize),
 			AccessibleTopology: accessibleTopology,
-			VolumeContext:      VolumeContext,
+			VolumeContext:      volumeContext,
+			ContentSource:      content,
 		},
 	}
+	if content != nil {
+		return response
+	}
 
 	if vol.SnapshotID != "" {
 		response.Volume.ContentSource = &csi.VolumeContentSource{
@@ -0,0 +1,355 @@
+package evs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/chnsz/golangsdk/openstack/evs/v2/cloudvolumes"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGetCloneAZ(t *testing.T) {
+	sourceVol := &cloudvolumes.Volume{ID: "source-id", AvailabilityZone: "az-1"}
+	requirement := &csi.TopologyRequirement{
+		Requisite: []*csi.Topology{
+			{Segments: map[string]string{topologyKey: "az-1"}},
+			{Segments: map[string]string{topologyKey: "az-2"}},
+		},
+		Preferred: []*csi.Topology{
+			{Segments: map[string]string{topologyKey: "az-2"}},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		volumeAz    string
+		specified   bool
+		requirement *csi.TopologyRequirement
+		expected    string
+		code        codes.Code
+		description string
+	}{
+		{
+			name:        "test1",
+			volumeAz:    "",
+			expected:    "az-1",
+			description: "no AZ, the clone is created in the AZ of the source volume",
+		},
+		{
+			name:        "test2",
+			volumeAz:    "az-1",
+			specified:   true,
+			expected:    "az-1",
+			description: "the specified AZ is the same as the source volume",
+		},
+		{
+			name:        "test3",
+			volumeAz:    "az-2",
+			requirement: requirement,
+			expected:    "az-1",
+			description: "the AZ is picked from the topology, which also accepts the AZ of the source volume",
+		},
+		{
+			name:        "test4",
+			volumeAz:    "az-2",
+			specified:   true,
+			requirement: requirement,
+			code:        codes.InvalidArgument,
+			description: "the AZ is specified in the parameters and differs from the source volume",
+		},
+		{
+			name:     "test5",
+			volumeAz: "az-2",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: map[string]string{topologyKey: "az-2"}}},
+			},
+			code:        codes.InvalidArgument,
+			description: "the topology does not accept the AZ of the source volume",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			az, err := getCloneAZ(testCase.volumeAz, testCase.specified, sourceVol, testCase.requirement)
+			if testCase.code != codes.OK {
+				if status.Code(err) != testCase.code {
+					t.Fatalf("expected code: %v, got error: %v", testCase.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if az != testCase.expected {
+				t.Errorf("expected: %s, got: %s", testCase.expected, az)
+			}
+		})
+	}
+}
+
+func TestTopologyContainsZone(t *testing.T) {
+	tests := []struct {
+		name        string
+		requirement *csi.TopologyRequirement
+		expected    bool
+		description string
+	}{
+		{
+			name:        "test1",
+			requirement: nil,
+			expected:    true,
+			description: "no topology requirement",
+		},
+		{
+			name:        "test2",
+			requirement: &csi.TopologyRequirement{},
+			expected:    true,
+			description: "empty topology requirement",
+		},
+		{
+			name: "test3",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: map[string]string{topologyKey: "az-1"}}},
+			},
+			expected:    true,
+			description: "the zone is in the requisite topologies",
+		},
+		{
+			name: "test4",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: map[string]string{topologyKey: "az-2"}}},
+				Preferred: []*csi.Topology{{Segments: map[string]string{topologyKey: "az-1"}}},
+			},
+			expected:    true,
+			description: "the zone is in the preferred topologies",
+		},
+		{
+			name: "test5",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: map[string]string{topologyKey: "az-2"}}},
+				Preferred: []*csi.Topology{{Segments: map[string]string{topologyKey: "az-2"}}},
+			},
+			expected:    false,
+			description: "the zone is not in the topologies",
+		},
+		{
+			name: "test6",
+			requirement: &csi.TopologyRequirement{
+				Requisite: []*csi.Topology{{Segments: map[string]string{"topology.kubernetes.io/zone": "az-1"}}},
+			},
+			expected:    false,
+			description: "the zone is only in the segments of other topology keys",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			contains := topologyContainsZone(testCase.requirement, "az-1")
+			if contains != testCase.expected {
+				t.Errorf("expected: %v, got: %v", testCase.expected, contains)
+			}
+		})
+	}
+}
+
+func newVolumeCapability(block bool, mode csi.VolumeCapability_AccessMode_Mode) *csi.VolumeCapability {
+	capability := &csi.VolumeCapability{AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode}}
+	if block {
+		capability.AccessType = &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}
+	} else {
+		capability.AccessType = &csi.VolumeCapability_Mount{Mount: &csi.VolumeCapability_MountVolume{}}
+	}
+	return capability
+}
+
+func TestValidateAccessMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		block       bool
+		mode        csi.VolumeCapability_AccessMode_Mode
+		multiattach bool
+		code        codes.Code
+		description string
+	}{
+		{
+			name:        "test1",
+			mode:        csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			description: "single node writer",
+		},
+		{
+			name:        "test2",
+			block:       true,
+			mode:        csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			multiattach: true,
+			description: "multiple writers of the shareable block volume",
+		},
+		{
+			name:        "test3",
+			mode:        csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			multiattach: true,
+			code:        codes.InvalidArgument,
+			description: "multiple writers of the shareable file system",
+		},
+		{
+			name:        "test4",
+			block:       true,
+			mode:        csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			code:        codes.InvalidArgument,
+			description: "multiple writers of the non-shareable block volume",
+		},
+		{
+			name:        "test5",
+			mode:        csi.VolumeCapability_AccessMode_MULTI_NODE_READER_ONLY,
+			multiattach: true,
+			code:        codes.InvalidArgument,
+			description: "multiple readers are not supported",
+		},
+		{
+			name:        "test6",
+			mode:        csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+			description: "single node single writer",
+		},
+		{
+			name:        "test7",
+			block:       true,
+			mode:        csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+			description: "single node multiple writers",
+		},
+		{
+			name:        "test8",
+			mode:        csi.VolumeCapability_AccessMode_UNKNOWN,
+			code:        codes.InvalidArgument,
+			description: "unknown access mode",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			capability := newVolumeCapability(testCase.block, testCase.mode)
+			err := validateAccessMode(capability, testCase.multiattach)
+			if status.Code(err) != testCase.code {
+				t.Errorf("expected code: %v, got error: %v", testCase.code, err)
+			}
+		})
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	cs := &ControllerServer{Driver: &EvsDriver{cluster: "cluster-id"}}
+	pvcInfo := map[string]string{
+		PvcNameTag: "pvc-name",
+		PvcNsTag:   "default",
+		PvNameKey:  "pv-name",
+	}
+
+	tests := []struct {
+		name        string
+		parameters  map[string]string
+		expected    map[string]string
+		code        codes.Code
+		description string
+	}{
+		{
+			name:        "test1",
+			parameters:  map[string]string{},
+			expected:    nil,
+			description: "no tags",
+		},
+		{
+			name:        "test2",
+			parameters:  map[string]string{"tags": "team=storage, env = prod"},
+			expected:    map[string]string{"team": "storage", "env": "prod"},
+			description: "the spaces around the keys and values are trimmed",
+		},
+		{
+			name:        "test3",
+			parameters:  map[string]string{"tags": "owner=,url=http://a=b"},
+			expected:    map[string]string{"owner": "", "url": "http://a=b"},
+			description: "the value can be empty or contain =",
+		},
+		{
+			name:        "test4",
+			parameters:  map[string]string{"tags": "team"},
+			code:        codes.InvalidArgument,
+			description: "the tag has no value",
+		},
+		{
+			name:        "test5",
+			parameters:  map[string]string{"tags": "=storage"},
+			code:        codes.InvalidArgument,
+			description: "the key is empty",
+		},
+		{
+			name:        "test6",
+			parameters:  map[string]string{"tags": "team=storage,"},
+			code:        codes.InvalidArgument,
+			description: "trailing comma",
+		},
+		{
+			name: "test7",
+			parameters: map[string]string{
+				"tagPvcInfo":             "true",
+				PvcNameTag:               pvcInfo[PvcNameTag],
+				PvcNsTag:                 pvcInfo[PvcNsTag],
+				PvNameKey:                pvcInfo[PvNameKey],
+				"csi.storage.k8s.io/foo": "bar",
+			},
+			expected: map[string]string{
+				PvcNameTagKey:   "pvc-name",
+				PvcNsTagKey:     "default",
+				ClusterIDTagKey: "cluster-id",
+			},
+			description: "the PVC info is added as tags",
+		},
+		{
+			name: "test8",
+			parameters: map[string]string{
+				"tags":       "team=storage",
+				"tagPvcInfo": "false",
+				PvcNameTag:   pvcInfo[PvcNameTag],
+				PvcNsTag:     pvcInfo[PvcNsTag],
+			},
+			expected:    map[string]string{"team": "storage"},
+			description: "the PVC info is not added when tagPvcInfo is false",
+		},
+		{
+			name: "test9",
+			parameters: map[string]string{
+				"tags":       PvcNameTagKey + "=custom",
+				"tagPvcInfo": "true",
+				PvcNameTag:   pvcInfo[PvcNameTag],
+			},
+			expected: map[string]string{
+				PvcNameTagKey:   "pvc-name",
+				ClusterIDTagKey: "cluster-id",
+			},
+			description: "the PVC info overrides the tags, the empty PVC info is skipped",
+		},
+		{
+			name:        "test10",
+			parameters:  map[string]string{"tagPvcInfo": "yes"},
+			code:        codes.InvalidArgument,
+			description: "tagPvcInfo is not a boolean",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			volumeTags, err := cs.parseTags(testCase.parameters)
+			if testCase.code != codes.OK {
+				if status.Code(err) != testCase.code {
+					t.Fatalf("expected code: %v, got error: %v", testCase.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(volumeTags, testCase.expected) {
+				t.Errorf("expected: %v, got: %v", testCase.expected, volumeTags)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package evs
+
+import (
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+)
+
+const (
+	// DeleteProtectionParam in StorageClass parameters, the value is saved as the DeleteProtectionKey metadata
+	DeleteProtectionParam = "deleteProtection"
+
+	// deleteProtectionRefuse refuses to delete the protected volumes
+	deleteProtectionRefuse = "refuse"
+	// deleteProtectionRetain renames the protected volumes with retainedVolumePrefix instead of deleting them,
+	// the volumes are no longer managed by the driver
+	deleteProtectionRetain = "retain"
+
+	retainedVolumePrefix = "retained-"
+)
+
+// getDeleteProtection validates the deleteProtection parameter, which is true, false, refuse or retain.
+// The policy in the cloud config is used when it's true.
+func getDeleteProtection(parameters map[string]string) (string, error) {
+	v := parameters[DeleteProtectionParam]
+	switch v {
+	case "", "true", "false", deleteProtectionRefuse, deleteProtectionRetain:
+		return v, nil
+	default:
+		return "", status.Errorf(codes.InvalidArgument, "%s error, expected true, false, %s or %s, but got %s",
+			DeleteProtectionParam, deleteProtectionRefuse, deleteProtectionRetain, v)
+	}
+}
+
+// getDeleteProtectionPolicy returns the action on the volume when deleted, it's empty if the volume is not protected.
+// The volume is protected by the DeleteProtectionKey metadata or the delete-protection of the cloud config,
+// the metadata false opts out of the cloud config.
+func getDeleteProtectionPolicy(cc *config.CloudCredentials, metadata map[string]string) string {
+	v, ok := metadata[DeleteProtectionKey]
+	if !ok && cc.Evs.DeleteProtection {
+		v = "true"
+	}
+	switch v {
+	case deleteProtectionRefuse, deleteProtectionRetain:
+		return v
+	case "true":
+		return cc.Evs.DeleteProtectionPolicy
+	default:
+		return ""
+	}
+}
+
+// protectVolumeDeletion applies the delete protection of the volume, it returns whether the volume is retained.
+// codes.FailedPrecondition is returned if the deletion is refused.
+func protectVolumeDeletion(cc *config.CloudCredentials, volumeID string) (bool, error) {
+	metadata, err := services.GetVolumeMetadata(cc, volumeID)
+	if err != nil {
+		return false, err
+	}
+
+	policy := getDeleteProtectionPolicy(cc, metadata)
+	switch policy {
+	case "":
+		return false, nil
+	case deleteProtectionRetain:
+		return true, retainVolume(cc, volumeID)
+	case deleteProtectionRefuse:
+		return false, status.Errorf(codes.FailedPrecondition, "Volume %s is protected from deletion, "+
+			"set the metadata %s to false to delete it", volumeID, DeleteProtectionKey)
+	default:
+		return false, status.Errorf(codes.FailedPrecondition, "Volume %s is protected from deletion, "+
+			"unknown delete protection policy: %s", volumeID, policy)
+	}
+}
+
+// retainVolume renames the volume with retainedVolumePrefix and removes the metadata of the driver,
+// so that the volume is kept and no longer managed by the driver.
+func retainVolume(cc *config.CloudCredentials, volumeID string) error {
+	vol, err := services.GetVolume(cc, volumeID)
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(vol.Name, retainedVolumePrefix) {
+		if err = services.RenameVolume(cc, volumeID, retainedVolumePrefix+vol.Name); err != nil {
+			return err
+		}
+	}
+	for _, key := range []string{CsiClusterNodeIDKey, CreateForVolumeIDKey} {
+		if err = services.DeleteVolumeMetadata(cc, volumeID, key); err != nil {
+			return err
+		}
+	}
+	log.Infof("Volume %s is protected from deletion, retained as %s%s", volumeID, retainedVolumePrefix,
+		strings.TrimPrefix(vol.Name, retainedVolumePrefix))
+	return nil
+}
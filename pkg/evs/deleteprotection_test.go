@@ -0,0 +1,69 @@
+package evs
+
+import (
+	"testing"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+func TestGetDeleteProtectionPolicy(t *testing.T) {
+	tests := []struct {
+		name             string
+		deleteProtection bool
+		metadata         map[string]string
+		expected         string
+		description      string
+	}{
+		{
+			name:        "test1",
+			metadata:    map[string]string{},
+			expected:    "",
+			description: "the volume is not protected",
+		},
+		{
+			name:        "test2",
+			metadata:    map[string]string{DeleteProtectionKey: "true"},
+			expected:    deleteProtectionRetain,
+			description: "the volume is protected with the policy of the cloud config",
+		},
+		{
+			name:        "test3",
+			metadata:    map[string]string{DeleteProtectionKey: deleteProtectionRefuse},
+			expected:    deleteProtectionRefuse,
+			description: "the policy of the volume takes precedence over the cloud config",
+		},
+		{
+			name:             "test4",
+			deleteProtection: true,
+			metadata:         map[string]string{"hw:passthrough": "true"},
+			expected:         deleteProtectionRetain,
+			description:      "all the volumes are protected by the cloud config",
+		},
+		{
+			name:             "test5",
+			deleteProtection: true,
+			metadata:         map[string]string{DeleteProtectionKey: "false"},
+			expected:         "",
+			description:      "the volume opts out of the protection of the cloud config",
+		},
+		{
+			name:        "test6",
+			metadata:    map[string]string{DeleteProtectionKey: "yes"},
+			expected:    "",
+			description: "the unknown values are ignored",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			cc := &config.CloudCredentials{}
+			cc.Evs.DeleteProtection = testCase.deleteProtection
+			cc.Evs.DeleteProtectionPolicy = deleteProtectionRetain
+
+			policy := getDeleteProtectionPolicy(cc, testCase.metadata)
+			if policy != testCase.expected {
+				t.Errorf("expected: %q, got: %q", testCase.expected, policy)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package evs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/chnsz/golangsdk/openstack/evs/v2/cloudvolumes"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+)
+
+// getServerAttachment returns the attachment of the volume on the ECS instance, it's nil if not attached
+func getServerAttachment(volume *cloudvolumes.Volume, instanceID string) *cloudvolumes.Attachment {
+	for i := range volume.Attachments {
+		if volume.Attachments[i].ServerID == instanceID {
+			return &volume.Attachments[i]
+		}
+	}
+	return nil
+}
+
+// isNodeOutOfService returns whether the node of the ECS instance is tainted with node.kubernetes.io/out-of-service.
+// The node is assumed to be in service if it's not found.
+func isNodeOutOfService(instanceID string) bool {
+	node, err := getInstanceNode(instanceID)
+	if err != nil {
+		log.Warningf("Failed to get the node of ECS instance %s, assume it is in service: %v", instanceID, err)
+		return false
+	}
+	return node != nil && hasOutOfServiceTaint(node)
+}
+
+// getInstanceNode returns the node of the ECS instance, it's nil if the driver is not registered on any node
+// with the instance ID as its node ID.
+func getInstanceNode(instanceID string) (*corev1.Node, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	csiNodes, err := client.StorageV1().CSINodes().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodeName := getCSINodeName(csiNodes.Items, instanceID)
+	if nodeName == "" {
+		return nil, nil
+	}
+	return client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+}
+
+// getCSINodeName returns the name of the node registered by the driver with the ECS instance ID as its node ID
+func getCSINodeName(csiNodes []storagev1.CSINode, instanceID string) string {
+	for _, csiNode := range csiNodes {
+		for _, driver := range csiNode.Spec.Drivers {
+			if driver.Name == driverName && driver.NodeID == instanceID {
+				return csiNode.Name
+			}
+		}
+	}
+	return ""
+}
+
+func hasOutOfServiceTaint(node *corev1.Node) bool {
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == corev1.TaintNodeOutOfService {
+			return true
+		}
+	}
+	return false
+}
+
+// detachVolume detaches the volume from the ECS instance and checks that the volume is really detached.
+// The volume is forcibly detached only when the instance does not exist, or the node is shut down and tainted
+// with node.kubernetes.io/out-of-service by the administrator. Otherwise, the volume is detached normally,
+// so that the data being written by the instance is not lost.
+func detachVolume(cc *config.CloudCredentials, instanceID string, volume *cloudvolumes.Volume,
+	attachment *cloudvolumes.Attachment) error {
+	_, err := services.GetServer(cc, instanceID)
+	switch {
+	case status.Code(err) == codes.NotFound:
+		log.Warningf("ECS instance %s does not exist, forcibly detach volume %s", instanceID, volume.ID)
+		err = services.ForceDetachVolume(cc, volume.ID, attachment.AttachmentID)
+	case err != nil:
+		return err
+	case isNodeOutOfService(instanceID):
+		log.Warningf("The node of ECS instance %s is out of service, forcibly detach volume %s", instanceID, volume.ID)
+		err = services.ForceDetachVolumeCompleted(cc, instanceID, volume.ID)
+	default:
+		err = services.DetachVolumeCompleted(cc, instanceID, volume.ID)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "Ecs.0111") {
+			log.Warningf("Warning, the volume %s is not in the server %s attach volume list, skip unpublishing",
+				volume.ID, instanceID)
+			return nil
+		}
+		return status.Errorf(codes.Internal, "Error unpublishing volume %s from server %s, error: %v",
+			volume.ID, instanceID, err)
+	}
+
+	if err = services.WaitForVolumeDetached(cc, instanceID, volume.ID); err != nil {
+		return status.Errorf(codes.Internal, "Error waiting for volume %s to be detached from server %s: %v",
+			volume.ID, instanceID, err)
+	}
+	return nil
+}
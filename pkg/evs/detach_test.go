@@ -0,0 +1,108 @@
+package evs
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetCSINodeName(t *testing.T) {
+	csiNodes := []storagev1.CSINode{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Spec: storagev1.CSINodeSpec{
+				Drivers: []storagev1.CSINodeDriver{
+					{Name: "obs.csi.huaweicloud.com", NodeID: "instance-2"},
+					{Name: driverName, NodeID: "instance-1"},
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+			Spec: storagev1.CSINodeSpec{
+				Drivers: []storagev1.CSINodeDriver{
+					{Name: driverName, NodeID: "instance-2"},
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		instanceID  string
+		expected    string
+		description string
+	}{
+		{
+			name:        "test1",
+			instanceID:  "instance-1",
+			expected:    "node-1",
+			description: "the node registered by the driver with the instance ID",
+		},
+		{
+			name:        "test2",
+			instanceID:  "instance-2",
+			expected:    "node-2",
+			description: "the node IDs of the other drivers are ignored",
+		},
+		{
+			name:        "test3",
+			instanceID:  "instance-3",
+			expected:    "",
+			description: "the driver is not registered with the instance ID",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			nodeName := getCSINodeName(csiNodes, testCase.instanceID)
+			if nodeName != testCase.expected {
+				t.Errorf("expected: %q, got: %q", testCase.expected, nodeName)
+			}
+		})
+	}
+}
+
+func TestHasOutOfServiceTaint(t *testing.T) {
+	tests := []struct {
+		name        string
+		taints      []corev1.Taint
+		expected    bool
+		description string
+	}{
+		{
+			name:        "test1",
+			taints:      nil,
+			expected:    false,
+			description: "the node is not tainted",
+		},
+		{
+			name: "test2",
+			taints: []corev1.Taint{
+				{Key: corev1.TaintNodeUnreachable, Effect: corev1.TaintEffectNoExecute},
+			},
+			expected:    false,
+			description: "the unreachable node is not out of service",
+		},
+		{
+			name: "test3",
+			taints: []corev1.Taint{
+				{Key: corev1.TaintNodeUnreachable, Effect: corev1.TaintEffectNoExecute},
+				{Key: corev1.TaintNodeOutOfService, Value: "nodeshutdown", Effect: corev1.TaintEffectNoExecute},
+			},
+			expected:    true,
+			description: "the node is tainted with node.kubernetes.io/out-of-service",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			node := &corev1.Node{Spec: corev1.NodeSpec{Taints: testCase.taints}}
+			if v := hasOutOfServiceTaint(node); v != testCase.expected {
+				t.Errorf("expected: %v, got: %v", testCase.expected, v)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
 	log "k8s.io/klog/v2"
 
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
@@ -21,7 +22,7 @@ const (
 
 var (
 	// CSI spec version
-	specVersion = "1.5.0"
+	specVersion = "1.11.0"
 )
 
 type EvsDriver struct { //nolint: revive
@@ -30,16 +31,23 @@ type EvsDriver struct { //nolint: revive
 	version  string
 	endpoint string
 	cluster  string
+	nodeName string
+
+	maxVolumesPerNode int64
 
 	cloudCredentials *config.CloudCredentials
 
 	ids *identityServer
 	cs  *ControllerServer
+	gcs *groupControllerServer
 	ns  *nodeServer
 
-	vcap  []*csi.VolumeCapability_AccessMode
-	cscap []*csi.ControllerServiceCapability
-	nscap []*csi.NodeServiceCapability
+	populator *obsPopulator
+
+	vcap   []*csi.VolumeCapability_AccessMode
+	cscap  []*csi.ControllerServiceCapability
+	gcscap []*csi.GroupControllerServiceCapability
+	nscap  []*csi.NodeServiceCapability
 }
 
 func NewDriver(cc *config.CloudCredentials, endpoint, cluster, nodeID string) *EvsDriver {
@@ -63,9 +71,21 @@ func NewDriver(cc *config.CloudCredentials, endpoint, cluster, nodeID string) *E
 			csi.ControllerServiceCapability_RPC_EXPAND_VOLUME,
 			csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES,
 			csi.ControllerServiceCapability_RPC_GET_VOLUME,
+			csi.ControllerServiceCapability_RPC_CLONE_VOLUME,
+			csi.ControllerServiceCapability_RPC_GET_CAPACITY,
+			csi.ControllerServiceCapability_RPC_MODIFY_VOLUME,
+			csi.ControllerServiceCapability_RPC_VOLUME_CONDITION,
+			csi.ControllerServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
+		})
+	d.AddGroupControllerServiceCapabilities(
+		[]csi.GroupControllerServiceCapability_RPC_Type{
+			csi.GroupControllerServiceCapability_RPC_CREATE_DELETE_GET_VOLUME_GROUP_SNAPSHOT,
 		})
 	d.AddVolumeCapabilityAccessModes([]csi.VolumeCapability_AccessMode_Mode{
 		csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER,
+		csi.VolumeCapability_AccessMode_SINGLE_NODE_MULTI_WRITER,
+		csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
 	})
 
 	d.AddNodeServiceCapabilities(
@@ -73,10 +93,14 @@ func NewDriver(cc *config.CloudCredentials, endpoint, cluster, nodeID string) *E
 			csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
 			csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
 			csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+			csi.NodeServiceCapability_RPC_VOLUME_MOUNT_GROUP,
+			csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+			csi.NodeServiceCapability_RPC_SINGLE_NODE_MULTI_WRITER,
 		})
 
 	d.ids = &identityServer{Driver: d}
 	d.cs = &ControllerServer{Driver: d}
+	d.gcs = &groupControllerServer{Driver: d}
 	d.ns = &nodeServer{Driver: d}
 
 	return d
@@ -99,6 +123,23 @@ func (d *EvsDriver) AddControllerServiceCapabilities(cl []csi.ControllerServiceC
 	d.cscap = csc
 }
 
+func (d *EvsDriver) AddGroupControllerServiceCapabilities(cl []csi.GroupControllerServiceCapability_RPC_Type) {
+	var gcsc []*csi.GroupControllerServiceCapability
+
+	for _, c := range cl {
+		log.Infof("Enabling group controller service capability: %v", c.String())
+		gcsc = append(gcsc, &csi.GroupControllerServiceCapability{
+			Type: &csi.GroupControllerServiceCapability_Rpc{
+				Rpc: &csi.GroupControllerServiceCapability_RPC{
+					Type: c,
+				},
+			},
+		})
+	}
+
+	d.gcscap = gcsc
+}
+
 func (d *EvsDriver) AddVolumeCapabilityAccessModes(vc []csi.VolumeCapability_AccessMode_Mode) []*csi.VolumeCapability_AccessMode {
 	var vca []*csi.VolumeCapability_AccessMode
 	for _, c := range vc {
@@ -150,8 +191,30 @@ func (d *EvsDriver) SetupDriver(mount mounts.IMount, metadata metadatas.IMetadat
 	d.ns.Metadata = metadata
 }
 
+// SetNodeLimits sets the name of the node and the maximum number of volumes that can be attached to the node,
+// the value is computed from the ECS flavor when maxVolumesPerNode is 0.
+func (d *EvsDriver) SetNodeLimits(nodeName string, maxVolumesPerNode int64) {
+	d.nodeName = nodeName
+	d.maxVolumesPerNode = maxVolumesPerNode
+}
+
+// EnableObsPopulator enables the populator of the volumes from OBS objects, the volumes are attached to
+// the instance which the plugin is running on temporarily, only one populator should be running in the cluster.
+func (d *EvsDriver) EnableObsPopulator(mount mounts.IMount, metadata metadatas.IMetadata) error {
+	populator, err := newObsPopulator(d, mount, metadata)
+	if err != nil {
+		return err
+	}
+	d.populator = populator
+	return nil
+}
+
 func (d *EvsDriver) Run() {
+	if d.populator != nil {
+		go d.populator.run(wait.NeverStop)
+	}
+
 	s := NewNonBlockingGRPCServer()
-	s.Start(d.endpoint, d.ids, d.cs, d.ns)
+	s.Start(d.endpoint, d.ids, d.cs, d.gcs, d.ns)
 	s.Wait()
 }
@@ -0,0 +1,191 @@
+package evs
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+	"k8s.io/utils/exec"
+)
+
+// The StorageClass parameters of the file system creation options,
+// they are passed to the node through the volume context.
+const (
+	BlockSizeKey                = "blockSize"
+	InodeSizeKey                = "inodeSize"
+	Ext4FeaturesKey             = "ext4Features"
+	XfsReflinkKey               = "xfsReflink"
+	ReservedBlocksPercentageKey = "reservedBlocksPercentage"
+)
+
+var fsOptionKeys = []string{BlockSizeKey, InodeSizeKey, Ext4FeaturesKey, XfsReflinkKey,
+	ReservedBlocksPercentageKey}
+
+// parseFsOptions validates the file system creation options of the parameters,
+// and returns them to be added to the volume context.
+func parseFsOptions(parameters map[string]string) (map[string]string, error) {
+	options := make(map[string]string)
+	for _, key := range fsOptionKeys {
+		if v := strings.TrimSpace(parameters[key]); v != "" {
+			options[key] = v
+		}
+	}
+
+	for _, key := range []string{BlockSizeKey, InodeSizeKey} {
+		if v, ok := options[key]; ok {
+			if size, err := strconv.Atoi(v); err != nil || size <= 0 {
+				return nil, status.Errorf(codes.InvalidArgument,
+					"%s error, expected a positive number, but got %s", key, v)
+			}
+		}
+	}
+	if v, ok := options[XfsReflinkKey]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"%s error, expected a boolean, but got %s, error: %s", XfsReflinkKey, v, err)
+		}
+	}
+	if v, ok := options[ReservedBlocksPercentageKey]; ok {
+		if pct, err := strconv.Atoi(v); err != nil || pct < 0 || pct > 50 {
+			return nil, status.Errorf(codes.InvalidArgument,
+				"%s error, expected a number between 0 and 50, but got %s", ReservedBlocksPercentageKey, v)
+		}
+	}
+	return options, nil
+}
+
+func isExtFs(fsType string) bool {
+	return fsType == "ext2" || fsType == "ext3" || fsType == "ext4"
+}
+
+// getFormatOptions returns the options of mkfs from the volume context, the options which are not supported
+// by the file system are ignored.
+func getFormatOptions(fsType string, volumeContext map[string]string) []string {
+	var options []string
+	switch {
+	case isExtFs(fsType):
+		if v := volumeContext[BlockSizeKey]; v != "" {
+			options = append(options, "-b", v)
+		}
+		if v := volumeContext[InodeSizeKey]; v != "" {
+			options = append(options, "-I", v)
+		}
+		if v := volumeContext[Ext4FeaturesKey]; v != "" && fsType == "ext4" {
+			options = append(options, "-O", v)
+		}
+	case fsType == "xfs":
+		if v := volumeContext[BlockSizeKey]; v != "" {
+			options = append(options, "-b", "size="+v)
+		}
+		if v := volumeContext[InodeSizeKey]; v != "" {
+			options = append(options, "-i", "size="+v)
+		}
+		if v := volumeContext[XfsReflinkKey]; v != "" {
+			reflink, _ := strconv.ParseBool(v)
+			options = append(options, "-m", fmt.Sprintf("reflink=%d", boolToInt(reflink)))
+		}
+	}
+	return options
+}
+
+// tuneReservedBlocks sets the reserved blocks percentage of the ext file systems, otherwise mke2fs reserves
+// 5 percent of the blocks for the super-user by default.
+func tuneReservedBlocks(executor exec.Interface, devicePath, fsType string, volumeContext map[string]string) error {
+	pct := volumeContext[ReservedBlocksPercentageKey]
+	if pct == "" || !isExtFs(fsType) {
+		return nil
+	}
+	output, err := executor.Command("tune2fs", "-m", pct, devicePath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to set the reserved blocks percentage of %s to %s: %v, output: %s",
+			devicePath, pct, err, string(output))
+	}
+	return nil
+}
+
+// applyVolumeMountGroup makes the file system owned by the group, and sets the setgid bit of the directories
+// so that new files inherit the group. Only the root directory is changed for a new file system, the existing
+// files of the volume created from a snapshot, another volume, a backup or an image are changed recursively.
+// Nothing is changed if the root directory is already owned by the group, like the OnRootMismatch policy.
+func applyVolumeMountGroup(target, volumeMountGroup string) error {
+	if volumeMountGroup == "" {
+		return nil
+	}
+	gid, err := strconv.Atoi(volumeMountGroup)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Invalid volume mount group %s: %v", volumeMountGroup, err)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && int(st.Gid) == gid && info.Mode()&os.ModeSetgid != 0 {
+		log.Infof("The volume mount group %d is already applied to %s", gid, target)
+		return nil
+	}
+
+	empty, err := isEmptyFileSystem(target)
+	if err != nil {
+		return err
+	}
+	if empty {
+		log.Infof("Apply the volume mount group %d to %s", gid, target)
+		return setVolumeMountGroup(target, info, gid)
+	}
+	log.Infof("Apply the volume mount group %d to %s recursively", gid, target)
+	return filepath.WalkDir(target, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return setVolumeMountGroup(path, info, gid)
+	})
+}
+
+// isEmptyFileSystem returns whether the file system has no files except lost+found created by mkfs
+func isEmptyFileSystem(target string) (bool, error) {
+	entries, err := os.ReadDir(target)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Name() != "lost+found" {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// setVolumeMountGroup changes the group of the file, and makes it readable and writable by the group,
+// the symbolic links are changed themselves instead of the targets.
+func setVolumeMountGroup(path string, info fs.FileInfo, gid int) error {
+	if err := os.Lchown(path, -1, gid); err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	mode := info.Mode() | 0060
+	if info.IsDir() {
+		mode |= os.ModeSetgid | 0010
+	}
+	return os.Chmod(path, mode)
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package evs
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseFsOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		parameters  map[string]string
+		expected    map[string]string
+		code        codes.Code
+		description string
+	}{
+		{
+			name:        "test1",
+			parameters:  map[string]string{"type": "SSD"},
+			expected:    map[string]string{},
+			description: "no file system options, the other parameters are ignored",
+		},
+		{
+			name: "test2",
+			parameters: map[string]string{
+				BlockSizeKey:                "4096",
+				InodeSizeKey:                "512",
+				Ext4FeaturesKey:             "^has_journal,metadata_csum",
+				XfsReflinkKey:               "true",
+				ReservedBlocksPercentageKey: "0",
+			},
+			expected: map[string]string{
+				BlockSizeKey:                "4096",
+				InodeSizeKey:                "512",
+				Ext4FeaturesKey:             "^has_journal,metadata_csum",
+				XfsReflinkKey:               "true",
+				ReservedBlocksPercentageKey: "0",
+			},
+			description: "all the options",
+		},
+		{
+			name:        "test3",
+			parameters:  map[string]string{BlockSizeKey: " 4096 ", InodeSizeKey: "  "},
+			expected:    map[string]string{BlockSizeKey: "4096"},
+			description: "the spaces are trimmed, and the blank options are ignored",
+		},
+		{
+			name:        "test4",
+			parameters:  map[string]string{BlockSizeKey: "4k"},
+			code:        codes.InvalidArgument,
+			description: "the block size is not a number",
+		},
+		{
+			name:        "test5",
+			parameters:  map[string]string{InodeSizeKey: "-256"},
+			code:        codes.InvalidArgument,
+			description: "the inode size is negative",
+		},
+		{
+			name:        "test6",
+			parameters:  map[string]string{XfsReflinkKey: "enabled"},
+			code:        codes.InvalidArgument,
+			description: "xfsReflink is not a boolean",
+		},
+		{
+			name:        "test7",
+			parameters:  map[string]string{ReservedBlocksPercentageKey: "50"},
+			expected:    map[string]string{ReservedBlocksPercentageKey: "50"},
+			description: "the maximum reserved blocks percentage",
+		},
+		{
+			name:        "test8",
+			parameters:  map[string]string{ReservedBlocksPercentageKey: "51"},
+			code:        codes.InvalidArgument,
+			description: "the reserved blocks percentage is greater than 50",
+		},
+		{
+			name:        "test9",
+			parameters:  map[string]string{ReservedBlocksPercentageKey: "-1"},
+			code:        codes.InvalidArgument,
+			description: "the reserved blocks percentage is negative",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			options, err := parseFsOptions(testCase.parameters)
+			if testCase.code != codes.OK {
+				if status.Code(err) != testCase.code {
+					t.Fatalf("expected code: %v, got error: %v", testCase.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(options, testCase.expected) {
+				t.Errorf("expected: %v, got: %v", testCase.expected, options)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package evs
+
+import (
+	"sort"
+
+	"github.com/chnsz/golangsdk/openstack/evs/v2/snapshots"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/kubernetes-csi/csi-lib-utils/protosanitizer"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+)
+
+// groupControllerServer creates the crash-consistent snapshots of multiple volumes
+// through the EVS snapshot consistency groups.
+type groupControllerServer struct {
+	csi.UnimplementedGroupControllerServer
+
+	Driver *EvsDriver
+}
+
+func (gs *groupControllerServer) GroupControllerGetCapabilities(_ context.Context,
+	_ *csi.GroupControllerGetCapabilitiesRequest) (*csi.GroupControllerGetCapabilitiesResponse, error) {
+	return &csi.GroupControllerGetCapabilitiesResponse{
+		Capabilities: gs.Driver.gcscap,
+	}, nil
+}
+
+func (gs *groupControllerServer) CreateVolumeGroupSnapshot(_ context.Context,
+	req *csi.CreateVolumeGroupSnapshotRequest) (*csi.CreateVolumeGroupSnapshotResponse, error) {
+	log.Infof("CreateVolumeGroupSnapshot called with request %v", protosanitizer.StripSecrets(req))
+
+	credentials := gs.Driver.cloudCredentials
+	name := req.GetName()
+	volumeIDs := req.GetSourceVolumeIds()
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "CreateVolumeGroupSnapshot name cannot be empty")
+	}
+	if len(volumeIDs) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "CreateVolumeGroupSnapshot source volume IDs cannot be empty")
+	}
+
+	groups, err := services.ListSnapshotGroupsByName(credentials, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) > 1 {
+		return nil, status.Error(codes.Internal, "Multiple snapshot groups reported by EVS with same name")
+	}
+	if len(groups) == 1 {
+		group := &groups[0]
+		groupSnapshots, err := services.ListGroupSnapshots(credentials, group.ID)
+		if err != nil {
+			return nil, err
+		}
+		// The snapshots of the group are still being created when the previous request timed out
+		if len(groupSnapshots) < len(volumeIDs) && group.Status != services.SnapshotGroupErrorStatus &&
+			containsVolumes(volumeIDs, groupSnapshots) {
+			return nil, status.Errorf(codes.Unavailable, "The snapshots of group %s are being created, "+
+				"%d of %d are created", group.ID, len(groupSnapshots), len(volumeIDs))
+		}
+		if !sameVolumes(groupSnapshots, volumeIDs) {
+			return nil, status.Error(codes.AlreadyExists,
+				"CreateVolumeGroupSnapshot same name with different source volumes")
+		}
+		log.Infof("Snapshot group with name: %s already exist. detail: %v", name, group)
+		return buildGroupSnapshotResponse(group, groupSnapshots)
+	}
+
+	for _, volumeID := range volumeIDs {
+		if _, err = services.GetVolume(credentials, volumeID); err != nil {
+			return nil, err
+		}
+	}
+	opts := services.CreateSnapshotGroupOpts{
+		Name:      name,
+		VolumeIDs: volumeIDs,
+	}
+	groupID, err := services.CreateSnapshotGroup(credentials, opts)
+	if err != nil {
+		return nil, err
+	}
+	// Wait for the snapshots of all the volumes to be created, but not for them to be available,
+	// the external-snapshotter polls the status through GetVolumeGroupSnapshot.
+	groupSnapshots, err := services.WaitGroupSnapshotsCreated(credentials, groupID, len(volumeIDs))
+	if err != nil {
+		return nil, err
+	}
+	group, err := services.GetSnapshotGroup(credentials, groupID)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Successful create snapshot group %s of volumes %v", groupID, volumeIDs)
+	return buildGroupSnapshotResponse(group, groupSnapshots)
+}
+
+func buildGroupSnapshotResponse(group *services.SnapshotGroup, groupSnapshots []snapshots.Snapshot) (
+	*csi.CreateVolumeGroupSnapshotResponse, error) {
+	groupSnapshot, err := buildVolumeGroupSnapshot(group, groupSnapshots)
+	if err != nil {
+		return nil, err
+	}
+	return &csi.CreateVolumeGroupSnapshotResponse{GroupSnapshot: groupSnapshot}, nil
+}
+
+func buildVolumeGroupSnapshot(group *services.SnapshotGroup, groupSnapshots []snapshots.Snapshot) (
+	*csi.VolumeGroupSnapshot, error) {
+	if group.Status == services.SnapshotGroupErrorStatus {
+		return nil, status.Errorf(codes.FailedPrecondition, "Snapshot group %s is in %s status, "+
+			"the snapshot group creation failed", group.ID, group.Status)
+	}
+
+	readyToUse := group.Status == services.SnapshotGroupAvailableStatus
+	csiSnapshots := make([]*csi.Snapshot, 0, len(groupSnapshots))
+	for i := range groupSnapshots {
+		snap := &groupSnapshots[i]
+		if err := checkSnapshotFailed(snap); err != nil {
+			return nil, err
+		}
+		csiSnapshot := buildSnapshot(snap)
+		csiSnapshot.GroupSnapshotId = group.ID
+		readyToUse = readyToUse && csiSnapshot.ReadyToUse
+		csiSnapshots = append(csiSnapshots, csiSnapshot)
+	}
+
+	return &csi.VolumeGroupSnapshot{
+		GroupSnapshotId: group.ID,
+		Snapshots:       csiSnapshots,
+		CreationTime:    timestamppb.New(group.CreatedAt),
+		ReadyToUse:      readyToUse,
+	}, nil
+}
+
+// sameVolumes returns whether the snapshots of the group are taken from exactly the volumes
+func sameVolumes(groupSnapshots []snapshots.Snapshot, volumeIDs []string) bool {
+	if len(groupSnapshots) != len(volumeIDs) {
+		return false
+	}
+	snapVolumeIDs := make([]string, 0, len(groupSnapshots))
+	for _, snap := range groupSnapshots {
+		snapVolumeIDs = append(snapVolumeIDs, snap.VolumeID)
+	}
+	expected := append([]string{}, volumeIDs...)
+	sort.Strings(snapVolumeIDs)
+	sort.Strings(expected)
+	for i := range expected {
+		if expected[i] != snapVolumeIDs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// containsVolumes returns whether the snapshots of the group are all taken from the volumes
+func containsVolumes(volumeIDs []string, groupSnapshots []snapshots.Snapshot) bool {
+	volumes := make(map[string]bool, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		volumes[volumeID] = true
+	}
+	for _, snap := range groupSnapshots {
+		if !volumes[snap.VolumeID] {
+			return false
+		}
+	}
+	return true
+}
+
+func (gs *groupControllerServer) DeleteVolumeGroupSnapshot(_ context.Context,
+	req *csi.DeleteVolumeGroupSnapshotRequest) (*csi.DeleteVolumeGroupSnapshotResponse, error) {
+	log.Infof("DeleteVolumeGroupSnapshot called with request %v", protosanitizer.StripSecrets(req))
+
+	credentials := gs.Driver.cloudCredentials
+	groupID := req.GetGroupSnapshotId()
+	if groupID == "" {
+		return nil, status.Error(codes.InvalidArgument,
+			"Group snapshot ID must be provided in DeleteVolumeGroupSnapshot request")
+	}
+
+	if err := services.DeleteSnapshotGroup(credentials, groupID); err != nil {
+		if !common.IsNotFound(err) {
+			return nil, status.Errorf(codes.Internal, "Failed to delete snapshot group %s: %v", groupID, err)
+		}
+		log.Infof("Snapshot group %s is already deleted.", groupID)
+	}
+
+	// Make sure the snapshots of the group are deleted as well
+	for _, id := range req.GetSnapshotIds() {
+		if err := services.DeleteSnapshot(credentials, id); err != nil && !common.IsNotFound(err) {
+			return nil, status.Errorf(codes.Internal, "Failed to delete snapshot %s of group %s: %v",
+				id, groupID, err)
+		}
+	}
+	log.Infof("Successful delete snapshot group %s", groupID)
+	return &csi.DeleteVolumeGroupSnapshotResponse{}, nil
+}
+
+func (gs *groupControllerServer) GetVolumeGroupSnapshot(_ context.Context,
+	req *csi.GetVolumeGroupSnapshotRequest) (*csi.GetVolumeGroupSnapshotResponse, error) {
+	log.Infof("GetVolumeGroupSnapshot called with request %v", protosanitizer.StripSecrets(req))
+
+	credentials := gs.Driver.cloudCredentials
+	groupID := req.GetGroupSnapshotId()
+	if groupID == "" {
+		return nil, status.Error(codes.InvalidArgument,
+			"Group snapshot ID must be provided in GetVolumeGroupSnapshot request")
+	}
+
+	group, err := services.GetSnapshotGroup(credentials, groupID)
+	if err != nil {
+		return nil, err
+	}
+	groupSnapshots, err := services.ListGroupSnapshots(credentials, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if ids := req.GetSnapshotIds(); len(ids) > 0 && len(ids) != len(groupSnapshots) {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"Snapshot group %s has %d snapshots, but %d snapshot IDs are specified",
+			groupID, len(groupSnapshots), len(ids))
+	}
+
+	groupSnapshot, err := buildVolumeGroupSnapshot(group, groupSnapshots)
+	if err != nil {
+		return nil, err
+	}
+	return &csi.GetVolumeGroupSnapshotResponse{GroupSnapshot: groupSnapshot}, nil
+}
@@ -9,6 +9,8 @@ import (
 )
 
 type identityServer struct {
+	csi.UnimplementedIdentityServer
+
 	Driver *EvsDriver
 }
 
@@ -53,6 +55,13 @@ func (ids *identityServer) GetPluginCapabilities(_ context.Context, req *csi.Get
 					},
 				},
 			},
+			{
+				Type: &csi.PluginCapability_Service_{
+					Service: &csi.PluginCapability_Service{
+						Type: csi.PluginCapability_Service_GROUP_CONTROLLER_SERVICE,
+					},
+				},
+			},
 			{
 				Type: &csi.PluginCapability_VolumeExpansion_{
 					VolumeExpansion: &csi.PluginCapability_VolumeExpansion{
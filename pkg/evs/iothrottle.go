@@ -0,0 +1,205 @@
+package evs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+)
+
+const (
+	// NodeReadIOPSKey in StorageClass parameters and volume context, the read IOPS limit applied on the node
+	NodeReadIOPSKey = "nodeReadIOPS"
+	// NodeWriteIOPSKey in StorageClass parameters and volume context, the write IOPS limit applied on the node
+	NodeWriteIOPSKey = "nodeWriteIOPS"
+	// NodeReadBPSKey in StorageClass parameters and volume context, the read bytes per second applied on the node
+	NodeReadBPSKey = "nodeReadBPS"
+	// NodeWriteBPSKey in StorageClass parameters and volume context, the write bytes per second applied on the node
+	NodeWriteBPSKey = "nodeWriteBPS"
+
+	// PodUIDKey in volume context, which is passed to NodePublishVolume when podInfoOnMount is enabled
+	PodUIDKey = "csi.storage.k8s.io/pod.uid"
+
+	// cgroupRoot is the mount point of the cgroup v2 hierarchy of the host
+	cgroupRoot = "/sys/fs/cgroup"
+	ioMaxFile  = "io.max"
+)
+
+// ioMaxKeys maps the parameters to the keys of the io.max entries
+var ioMaxKeys = []struct {
+	param string
+	key   string
+}{
+	{NodeReadBPSKey, "rbps"},
+	{NodeWriteBPSKey, "wbps"},
+	{NodeReadIOPSKey, "riops"},
+	{NodeWriteIOPSKey, "wiops"},
+}
+
+// parseIOThrottle validates the I/O limits of the node and adds them to the volume context
+func parseIOThrottle(parameters, volumeContext map[string]string) error {
+	for _, k := range ioMaxKeys {
+		v, ok := parameters[k.param]
+		if !ok {
+			continue
+		}
+		if limit, err := strconv.ParseUint(v, 10, 64); err != nil || limit == 0 {
+			return status.Errorf(codes.InvalidArgument, "%s error, expected a positive integer, but got %s",
+				k.param, v)
+		}
+		volumeContext[k.param] = v
+	}
+	return nil
+}
+
+// buildIOMaxLimits returns the limits of the io.max entry, e.g. "rbps=1048576 wiops=100",
+// it's empty if there is no limit in the volume context.
+func buildIOMaxLimits(volumeContext map[string]string) string {
+	limits := make([]string, 0, len(ioMaxKeys))
+	for _, k := range ioMaxKeys {
+		if v := volumeContext[k.param]; v != "" {
+			limits = append(limits, fmt.Sprintf("%s=%s", k.key, v))
+		}
+	}
+	return strings.Join(limits, " ")
+}
+
+// getPodUID returns the UID of the pod which the volume is published to, it's parsed from the target path
+// when it's not in the volume context. The target path is /var/lib/kubelet/pods/<uid>/volumes/... in Filesystem
+// mode, and .../volumeDevices/publish/<pv name>/<uid> in Block mode.
+func getPodUID(volumeContext map[string]string, targetPath string) string {
+	if uid := volumeContext[PodUIDKey]; uid != "" {
+		return uid
+	}
+	parts := strings.Split(filepath.Clean(targetPath), string(filepath.Separator))
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "pods" && parts[i+1] != "" {
+			return parts[i+1]
+		}
+	}
+	if len(parts) > 2 && parts[len(parts)-3] == "publish" {
+		return parts[len(parts)-1]
+	}
+	return ""
+}
+
+// findPodCgroup returns the cgroup directory of the pod, both the systemd and cgroupfs drivers are supported
+func findPodCgroup(podUID string) (string, error) {
+	escaped := strings.ReplaceAll(podUID, "-", "_")
+	patterns := []string{
+		filepath.Join(cgroupRoot, "kubepods.slice", "kubepods-pod"+escaped+".slice"),
+		filepath.Join(cgroupRoot, "kubepods.slice", "kubepods-*.slice", "kubepods-*-pod"+escaped+".slice"),
+		filepath.Join(cgroupRoot, "kubepods", "pod"+podUID),
+		filepath.Join(cgroupRoot, "kubepods", "*", "pod"+podUID),
+	}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return "", err
+		}
+		if len(matches) > 0 {
+			return matches[0], nil
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// getDeviceNumber returns the major:minor of the block device
+func getDeviceNumber(devicePath string) (string, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(devicePath, &st); err != nil {
+		return "", err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return "", fmt.Errorf("%s is not a block device", devicePath)
+	}
+	dev := uint64(st.Rdev) //nolint:unconvert
+	return fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev)), nil
+}
+
+// getThrottledDevicePath returns the device which the I/O of the pod is issued to, it's the LUKS mapper device
+// of the encrypted volume, since the limits of the underlying EVS device have no effect on it.
+func (ns *nodeServer) getThrottledDevicePath(volumeID string) (string, error) {
+	if isLuksOpened(volumeID) {
+		return getLuksMapperPath(volumeID), nil
+	}
+	return getDevicePath(ns.Driver.cloudCredentials, volumeID, ns.Mount)
+}
+
+// applyIOThrottle writes the io.max entry of the volume device to the cgroup of the pod
+func (ns *nodeServer) applyIOThrottle(volumeID, targetPath string, volumeContext map[string]string) error {
+	limits := buildIOMaxLimits(volumeContext)
+	if limits == "" {
+		return nil
+	}
+
+	podUID := getPodUID(volumeContext, targetPath)
+	if podUID == "" {
+		return status.Errorf(codes.Internal, "Failed to get the pod UID of target path %s, "+
+			"the I/O limits of volume %s can not be applied", targetPath, volumeID)
+	}
+	cgroup, err := findPodCgroup(podUID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to find the cgroup v2 of pod %s: %v", podUID, err)
+	}
+	devicePath, err := ns.getThrottledDevicePath(volumeID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Unable to find devicePath for volume: %v", err)
+	}
+	device, err := getDeviceNumber(devicePath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to get the device number of %s: %v", devicePath, err)
+	}
+
+	entry := fmt.Sprintf("%s %s", device, limits)
+	if err = os.WriteFile(filepath.Join(cgroup, ioMaxFile), []byte(entry), 0); err != nil {
+		return status.Errorf(codes.Internal, "Failed to apply the I/O limits of volume %s to pod %s: %v",
+			volumeID, podUID, err)
+	}
+	log.Infof("Successfully applied the I/O limits %q of volume %s to pod %s", entry, volumeID, podUID)
+	return nil
+}
+
+// removeIOThrottle removes the io.max entry of the volume device from the cgroup of the pod,
+// it's ignored if the pod cgroup is already removed or there is no entry of the device.
+func (ns *nodeServer) removeIOThrottle(volumeID, targetPath string) error {
+	podUID := getPodUID(nil, targetPath)
+	if podUID == "" {
+		return nil
+	}
+	cgroup, err := findPodCgroup(podUID)
+	if err != nil {
+		return nil
+	}
+	ioMaxPath := filepath.Join(cgroup, ioMaxFile)
+	content, err := os.ReadFile(ioMaxPath)
+	if err != nil || len(strings.TrimSpace(string(content))) == 0 {
+		return nil
+	}
+
+	devicePath, err := ns.getThrottledDevicePath(volumeID)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Unable to find devicePath for volume: %v", err)
+	}
+	device, err := getDeviceNumber(devicePath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to get the device number of %s: %v", devicePath, err)
+	}
+	if !strings.Contains("\n"+string(content), "\n"+device+" ") {
+		return nil
+	}
+
+	entry := fmt.Sprintf("%s rbps=max wbps=max riops=max wiops=max", device)
+	if err = os.WriteFile(ioMaxPath, []byte(entry), 0); err != nil && !os.IsNotExist(err) {
+		return status.Errorf(codes.Internal, "Failed to remove the I/O limits of volume %s from pod %s: %v",
+			volumeID, podUID, err)
+	}
+	log.Infof("Successfully removed the I/O limits of volume %s from pod %s", volumeID, podUID)
+	return nil
+}
@@ -0,0 +1,110 @@
+package evs
+
+import "testing"
+
+func TestGetPodUID(t *testing.T) {
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+		targetPath    string
+		expected      string
+		description   string
+	}{
+		{
+			name:          "test1",
+			volumeContext: map[string]string{PodUIDKey: "uid-from-context"},
+			targetPath:    "/var/lib/kubelet/pods/uid-from-path/volumes/kubernetes.io~csi/pv-name/mount",
+			expected:      "uid-from-context",
+			description:   "the pod UID in the volume context takes precedence over the target path",
+		},
+		{
+			name:          "test2",
+			volumeContext: nil,
+			targetPath:    "/var/lib/kubelet/pods/uid-from-path/volumes/kubernetes.io~csi/pv-name/mount",
+			expected:      "uid-from-path",
+			description:   "Filesystem mode",
+		},
+		{
+			name:          "test3",
+			volumeContext: map[string]string{PodUIDKey: ""},
+			targetPath:    "/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pv-name/uid-from-path",
+			expected:      "uid-from-path",
+			description:   "Block mode, the empty pod UID in the volume context is ignored",
+		},
+		{
+			name:        "test4",
+			targetPath:  "/var/lib/kubelet/pods/uid-from-path/volumes/kubernetes.io~csi/pv-name/mount/",
+			expected:    "uid-from-path",
+			description: "the target path has a trailing slash",
+		},
+		{
+			name:        "test5",
+			targetPath:  "/var/lib/kubelet/plugins/kubernetes.io/csi/pv/pv-name/globalmount",
+			expected:    "",
+			description: "the staging path has no pod UID",
+		},
+		{
+			name:        "test6",
+			targetPath:  "/var/lib/kubelet/pods",
+			expected:    "",
+			description: "the pods directory without the pod UID",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			podUID := getPodUID(testCase.volumeContext, testCase.targetPath)
+			if podUID != testCase.expected {
+				t.Errorf("expected: %q, got: %q", testCase.expected, podUID)
+			}
+		})
+	}
+}
+
+func TestBuildIOMaxLimits(t *testing.T) {
+	tests := []struct {
+		name          string
+		volumeContext map[string]string
+		expected      string
+		description   string
+	}{
+		{
+			name:          "test1",
+			volumeContext: map[string]string{"type": "SSD"},
+			expected:      "",
+			description:   "no limits",
+		},
+		{
+			name:          "test2",
+			volumeContext: map[string]string{NodeWriteIOPSKey: "100", NodeReadBPSKey: "1048576"},
+			expected:      "rbps=1048576 wiops=100",
+			description:   "the limits are in the order of io.max",
+		},
+		{
+			name: "test3",
+			volumeContext: map[string]string{
+				NodeReadIOPSKey:  "200",
+				NodeWriteIOPSKey: "100",
+				NodeReadBPSKey:   "2097152",
+				NodeWriteBPSKey:  "1048576",
+			},
+			expected:    "rbps=2097152 wbps=1048576 riops=200 wiops=100",
+			description: "all the limits",
+		},
+		{
+			name:          "test4",
+			volumeContext: map[string]string{NodeReadIOPSKey: "", NodeWriteBPSKey: "1048576"},
+			expected:      "wbps=1048576",
+			description:   "the empty limits are ignored",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			limits := buildIOMaxLimits(testCase.volumeContext)
+			if limits != testCase.expected {
+				t.Errorf("expected: %q, got: %q", testCase.expected, limits)
+			}
+		})
+	}
+}
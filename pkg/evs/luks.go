@@ -0,0 +1,140 @@
+package evs
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+)
+
+const (
+	// NodeEncryptionKey in StorageClass parameters and volume context, the volumes are encrypted on the node
+	NodeEncryptionKey = "nodeEncryption"
+	// NodeEncryptionKmsIDKey in StorageClass parameters and volume context, the KMS key to wrap the data keys,
+	// defaults to kmsId.
+	NodeEncryptionKmsIDKey = "nodeEncryptionKmsId"
+
+	nodeEncryptionLuks = "luks"
+
+	// LuksKmsIDKey in volume metadata, the KMS key which wraps the data key
+	LuksKmsIDKey = "luks_kms_id"
+	// LuksWrappedKeyPrefix of the volume metadata keys, the wrapped data key is split into several keys,
+	// because the length of the metadata values is limited.
+	LuksWrappedKeyPrefix = "luks_wrapped_key_"
+
+	maxMetadataValueLength = 255
+	luksMapperPrefix       = "evs-"
+	luksMapperDir          = "/dev/mapper"
+)
+
+// parseNodeEncryption validates the node encryption parameters and adds them to the volume context
+func parseNodeEncryption(parameters, volumeContext map[string]string) error {
+	encryption := parameters[NodeEncryptionKey]
+	if encryption == "" {
+		return nil
+	}
+	if encryption != nodeEncryptionLuks {
+		return status.Errorf(codes.InvalidArgument, "%s error, expected %s, but got %s",
+			NodeEncryptionKey, nodeEncryptionLuks, encryption)
+	}
+	kmsID := parameters[NodeEncryptionKmsIDKey]
+	if kmsID == "" {
+		kmsID = parameters["kmsId"]
+	}
+	if kmsID == "" {
+		return status.Errorf(codes.InvalidArgument, "%s or kmsId is required when %s is %s",
+			NodeEncryptionKmsIDKey, NodeEncryptionKey, nodeEncryptionLuks)
+	}
+	volumeContext[NodeEncryptionKey] = encryption
+	volumeContext[NodeEncryptionKmsIDKey] = kmsID
+	return nil
+}
+
+func isLuksEncrypted(volumeContext map[string]string) bool {
+	return volumeContext[NodeEncryptionKey] == nodeEncryptionLuks
+}
+
+func getLuksMapperName(volumeID string) string {
+	return luksMapperPrefix + volumeID
+}
+
+func getLuksMapperPath(volumeID string) string {
+	return filepath.Join(luksMapperDir, getLuksMapperName(volumeID))
+}
+
+// getWrappedKey joins the wrapped data key from the volume metadata
+func getWrappedKey(metadata map[string]string) string {
+	keys := make([]string, 0)
+	for k := range metadata {
+		if strings.HasPrefix(k, LuksWrappedKeyPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, _ := strconv.Atoi(strings.TrimPrefix(keys[i], LuksWrappedKeyPrefix))
+		b, _ := strconv.Atoi(strings.TrimPrefix(keys[j], LuksWrappedKeyPrefix))
+		return a < b
+	})
+
+	var sb strings.Builder
+	for _, k := range keys {
+		sb.WriteString(metadata[k])
+	}
+	return sb.String()
+}
+
+// buildWrappedKeyMetadata splits the wrapped data key into the volume metadata
+func buildWrappedKeyMetadata(kmsID, wrappedKey string) map[string]string {
+	metadata := map[string]string{LuksKmsIDKey: kmsID}
+	for i := 0; len(wrappedKey) > 0; i++ {
+		n := len(wrappedKey)
+		if n > maxMetadataValueLength {
+			n = maxMetadataValueLength
+		}
+		metadata[fmt.Sprintf("%s%d", LuksWrappedKeyPrefix, i)] = wrappedKey[:n]
+		wrappedKey = wrappedKey[n:]
+	}
+	return metadata
+}
+
+// getLuksPassphrase returns the data key used as the LUKS passphrase, a new data key is generated by KMS and
+// stored in the volume metadata when the volume has none. The key is stored before the device is formatted,
+// so that it's never lost.
+func getLuksPassphrase(cc *config.CloudCredentials, volumeID string, volumeContext map[string]string,
+	generate bool) (string, error) {
+	metadata, err := services.GetVolumeMetadata(cc, volumeID)
+	if err != nil {
+		return "", err
+	}
+
+	if wrappedKey := getWrappedKey(metadata); wrappedKey != "" {
+		kmsID := metadata[LuksKmsIDKey]
+		if kmsID == "" {
+			kmsID = volumeContext[NodeEncryptionKmsIDKey]
+		}
+		return services.DecryptDataKey(cc, kmsID, wrappedKey)
+	}
+	if !generate {
+		return "", status.Errorf(codes.FailedPrecondition, "The LUKS data key of volume %s does not exist "+
+			"in the volume metadata", volumeID)
+	}
+
+	kmsID := volumeContext[NodeEncryptionKmsIDKey]
+	key, err := services.CreateDataKey(cc, kmsID)
+	if err != nil {
+		return "", err
+	}
+	if err = services.UpdateVolumeMetadata(cc, volumeID, buildWrappedKeyMetadata(kmsID, key.CipherText)); err != nil {
+		return "", err
+	}
+	log.Infof("The LUKS data key of volume %s is generated by KMS key %s", volumeID, kmsID)
+	return key.PlainText, nil
+}
@@ -0,0 +1,94 @@
+//go:build linux
+// +build linux
+
+package evs
+
+import (
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+	mountutils "k8s.io/mount-utils"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+// openLuksDevice formats the device with LUKS if it's not formatted yet, then opens the device,
+// and returns the path of the mapper device.
+func openLuksDevice(cc *config.CloudCredentials, mounter *mountutils.SafeFormatAndMount, volumeID,
+	devicePath string, volumeContext map[string]string) (string, error) {
+	mapperPath := getLuksMapperPath(volumeID)
+	if isLuksOpened(volumeID) {
+		log.Infof("The LUKS device %s of volume %s is already opened", mapperPath, volumeID)
+		return mapperPath, nil
+	}
+
+	format, err := mounter.GetDiskFormat(devicePath)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to get the format of device %s: %v", devicePath, err)
+	}
+	if format != "" && format != "crypto_LUKS" {
+		return "", status.Errorf(codes.FailedPrecondition, "The device %s of volume %s is already formatted "+
+			"as %s, it can not be encrypted with LUKS", devicePath, volumeID, format)
+	}
+
+	passphrase, err := getLuksPassphrase(cc, volumeID, volumeContext, format == "")
+	if err != nil {
+		return "", err
+	}
+	if format == "" {
+		log.Infof("Formatting the device %s of volume %s with LUKS", devicePath, volumeID)
+		if err = runCryptsetup(mounter, passphrase, "luksFormat", "--batch-mode", "--type", "luks2",
+			"--key-file", "-", devicePath); err != nil {
+			return "", err
+		}
+	}
+	if err = runCryptsetup(mounter, passphrase, "open", "--type", "luks", "--key-file", "-", devicePath,
+		getLuksMapperName(volumeID)); err != nil {
+		return "", err
+	}
+	log.Infof("Successfully opened the LUKS device %s of volume %s", mapperPath, volumeID)
+	return mapperPath, nil
+}
+
+// closeLuksDevice closes the mapper device of the volume, it's ignored if the device is not opened
+func closeLuksDevice(mounter *mountutils.SafeFormatAndMount, volumeID string) error {
+	if !isLuksOpened(volumeID) {
+		return nil
+	}
+	if err := runCryptsetup(mounter, "", "close", getLuksMapperName(volumeID)); err != nil {
+		return err
+	}
+	log.Infof("Successfully closed the LUKS device of volume %s", volumeID)
+	return nil
+}
+
+// resizeLuksDevice resizes the mapper device to the size of the underlying device
+func resizeLuksDevice(cc *config.CloudCredentials, mounter *mountutils.SafeFormatAndMount, volumeID string,
+	volumeContext map[string]string) error {
+	passphrase, err := getLuksPassphrase(cc, volumeID, volumeContext, false)
+	if err != nil {
+		return err
+	}
+	return runCryptsetup(mounter, passphrase, "resize", "--key-file", "-", getLuksMapperName(volumeID))
+}
+
+// isLuksOpened returns whether the mapper device of the volume is opened
+func isLuksOpened(volumeID string) bool {
+	_, err := os.Stat(getLuksMapperPath(volumeID))
+	return err == nil
+}
+
+func runCryptsetup(mounter *mountutils.SafeFormatAndMount, passphrase string, args ...string) error {
+	cmd := mounter.Exec.Command("cryptsetup", args...)
+	if passphrase != "" {
+		cmd.SetStdin(strings.NewReader(passphrase))
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return status.Errorf(codes.Internal, "Failed to run cryptsetup %s: %v, output: %s",
+			args[0], err, string(output))
+	}
+	return nil
+}
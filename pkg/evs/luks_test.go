@@ -0,0 +1,145 @@
+package evs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetWrappedKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		metadata    map[string]string
+		expected    string
+		description string
+	}{
+		{
+			name:        "test1",
+			metadata:    map[string]string{LuksKmsIDKey: "kms-id", "hw:passthrough": "true"},
+			expected:    "",
+			description: "no wrapped key",
+		},
+		{
+			name: "test2",
+			metadata: map[string]string{
+				LuksKmsIDKey:               "kms-id",
+				LuksWrappedKeyPrefix + "0": "abc",
+			},
+			expected:    "abc",
+			description: "the wrapped key in one value",
+		},
+		{
+			name: "test3",
+			metadata: map[string]string{
+				LuksWrappedKeyPrefix + "2": "c",
+				LuksWrappedKeyPrefix + "0": "a",
+				LuksWrappedKeyPrefix + "1": "b",
+			},
+			expected:    "abc",
+			description: "the values are joined in order",
+		},
+		{
+			name: "test4",
+			metadata: map[string]string{
+				LuksWrappedKeyPrefix + "0":  "0",
+				LuksWrappedKeyPrefix + "1":  "1",
+				LuksWrappedKeyPrefix + "2":  "2",
+				LuksWrappedKeyPrefix + "3":  "3",
+				LuksWrappedKeyPrefix + "4":  "4",
+				LuksWrappedKeyPrefix + "5":  "5",
+				LuksWrappedKeyPrefix + "6":  "6",
+				LuksWrappedKeyPrefix + "7":  "7",
+				LuksWrappedKeyPrefix + "8":  "8",
+				LuksWrappedKeyPrefix + "9":  "9",
+				LuksWrappedKeyPrefix + "10": "a",
+				LuksWrappedKeyPrefix + "11": "b",
+			},
+			expected:    "0123456789ab",
+			description: "the keys are sorted numerically rather than lexically",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			wrappedKey := getWrappedKey(testCase.metadata)
+			if wrappedKey != testCase.expected {
+				t.Errorf("expected: %q, got: %q", testCase.expected, wrappedKey)
+			}
+		})
+	}
+}
+
+func TestBuildWrappedKeyMetadata(t *testing.T) {
+	longKey := strings.Repeat("a", maxMetadataValueLength) + strings.Repeat("b", maxMetadataValueLength) + "c"
+
+	tests := []struct {
+		name        string
+		wrappedKey  string
+		expected    map[string]string
+		description string
+	}{
+		{
+			name:        "test1",
+			wrappedKey:  "",
+			expected:    map[string]string{LuksKmsIDKey: "kms-id"},
+			description: "empty wrapped key",
+		},
+		{
+			name:       "test2",
+			wrappedKey: "abc",
+			expected: map[string]string{
+				LuksKmsIDKey:               "kms-id",
+				LuksWrappedKeyPrefix + "0": "abc",
+			},
+			description: "the wrapped key fits in one value",
+		},
+		{
+			name:       "test3",
+			wrappedKey: strings.Repeat("a", maxMetadataValueLength),
+			expected: map[string]string{
+				LuksKmsIDKey:               "kms-id",
+				LuksWrappedKeyPrefix + "0": strings.Repeat("a", maxMetadataValueLength),
+			},
+			description: "the wrapped key has the maximum length of one value",
+		},
+		{
+			name:       "test4",
+			wrappedKey: longKey,
+			expected: map[string]string{
+				LuksKmsIDKey:               "kms-id",
+				LuksWrappedKeyPrefix + "0": strings.Repeat("a", maxMetadataValueLength),
+				LuksWrappedKeyPrefix + "1": strings.Repeat("b", maxMetadataValueLength),
+				LuksWrappedKeyPrefix + "2": "c",
+			},
+			description: "the wrapped key is split into several values",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			metadata := buildWrappedKeyMetadata("kms-id", testCase.wrappedKey)
+			if !reflect.DeepEqual(metadata, testCase.expected) {
+				t.Errorf("expected: %v, got: %v", testCase.expected, metadata)
+			}
+			if wrappedKey := getWrappedKey(metadata); wrappedKey != testCase.wrappedKey {
+				t.Errorf("expected wrapped key: %q, got: %q", testCase.wrappedKey, wrappedKey)
+			}
+		})
+	}
+}
+
+func TestWrappedKeyRoundTrip(t *testing.T) {
+	// A key split into more than 10 values checks the numeric order of the keys
+	wrappedKey := ""
+	for i := 0; i < 12; i++ {
+		wrappedKey += strings.Repeat(string(rune('a'+i)), maxMetadataValueLength)
+	}
+
+	metadata := buildWrappedKeyMetadata("kms-id", wrappedKey)
+	if len(metadata) != 13 {
+		t.Fatalf("expected: 13 metadata, got: %d", len(metadata))
+	}
+	if got := getWrappedKey(metadata); got != wrappedKey {
+		t.Errorf("expected: %q, got: %q", wrappedKey, got)
+	}
+}
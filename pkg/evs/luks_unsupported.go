@@ -0,0 +1,30 @@
+//go:build !linux
+// +build !linux
+
+package evs
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	mountutils "k8s.io/mount-utils"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+func openLuksDevice(_ *config.CloudCredentials, _ *mountutils.SafeFormatAndMount, _, _ string,
+	_ map[string]string) (string, error) {
+	return "", status.Error(codes.Unimplemented, "LUKS is not implemented for this OS")
+}
+
+func closeLuksDevice(_ *mountutils.SafeFormatAndMount, _ string) error {
+	return nil
+}
+
+func resizeLuksDevice(_ *config.CloudCredentials, _ *mountutils.SafeFormatAndMount, _ string,
+	_ map[string]string) error {
+	return status.Error(codes.Unimplemented, "LUKS is not implemented for this OS")
+}
+
+func isLuksOpened(_ string) bool {
+	return false
+}
@@ -0,0 +1,142 @@
+package evs
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+)
+
+const (
+	// maxVolumesLabel on the node overrides the maximum number of volumes that can be attached by the driver
+	maxVolumesLabel = driverName + "/max-volumes-per-node"
+
+	// the extra specs of ECS flavors
+	virtualizationTypeSpec = "ecs:virtualization_env_types"
+	generationSpec         = "ecs:generation"
+
+	// xenVirtualizationType is the virtualization type of Xen instances, others are KVM instances
+	xenVirtualizationType = "FusionCompute"
+
+	// the maximum number of disks of an instance, including the system disk
+	xenMaxDisks         = 24
+	kvmMaxDisks         = 24
+	kvmLatestMaxDisks   = 60
+	kvmLatestGeneration = 7
+)
+
+var generationRegexp = regexp.MustCompile(`(\d+)$`)
+
+// getMaxVolumesPerNode returns the maximum number of volumes that can be attached by the driver.
+// The node label takes precedence over the flag, both of them take precedence over the value
+// computed from the ECS flavor.
+func (d *EvsDriver) getMaxVolumesPerNode(instanceID string) int64 {
+	if v, err := getNodeMaxVolumesLabel(d.nodeName); err != nil {
+		log.Warningf("Failed to get the label %s of node %s: %v", maxVolumesLabel, d.nodeName, err)
+	} else if v > 0 {
+		log.Infof("The maximum number of volumes is %d from the label of node %s", v, d.nodeName)
+		return v
+	}
+
+	if d.maxVolumesPerNode > 0 {
+		return d.maxVolumesPerNode
+	}
+
+	maxVolumes, err := computeMaxVolumesPerNode(d.cloudCredentials, instanceID)
+	if err != nil {
+		log.Warningf("Failed to compute the maximum number of volumes of instance %s, use the default %d: %v",
+			instanceID, defaultMaxVolumes, err)
+		return defaultMaxVolumes
+	}
+	log.Infof("The maximum number of volumes of instance %s is %d", instanceID, maxVolumes)
+	return maxVolumes
+}
+
+// computeMaxVolumesPerNode computes the maximum number of volumes from the flavor and the virtualization type
+// of the instance, the disks not attached by the driver, e.g. the system disk, are excluded.
+func computeMaxVolumesPerNode(cc *config.CloudCredentials, instanceID string) (int64, error) {
+	server, err := services.GetServer(cc, instanceID)
+	if err != nil {
+		return 0, err
+	}
+
+	flavorID, ok := server.Flavor["id"].(string)
+	if !ok {
+		return 0, fmt.Errorf("the flavor of instance %s is not found", instanceID)
+	}
+	extraSpecs, err := services.GetFlavorExtraSpecs(cc, flavorID)
+	if err != nil {
+		return 0, err
+	}
+	maxDisks := getFlavorMaxDisks(extraSpecs)
+
+	unmanaged := 0
+	for _, attached := range server.VolumesAttached {
+		metadata, err := services.GetVolumeMetadata(cc, attached["id"])
+		if err != nil {
+			return 0, err
+		}
+		if _, ok := metadata[CsiClusterNodeIDKey]; !ok {
+			unmanaged++
+		}
+	}
+
+	maxVolumes := int64(maxDisks - unmanaged)
+	if maxVolumes < 1 {
+		maxVolumes = 1
+	}
+	return maxVolumes, nil
+}
+
+// getFlavorMaxDisks returns the maximum number of disks of the flavor, the KVM instances of
+// the 7th generation and later flavors support up to 60 disks.
+func getFlavorMaxDisks(extraSpecs map[string]string) int {
+	if extraSpecs[virtualizationTypeSpec] == xenVirtualizationType {
+		return xenMaxDisks
+	}
+
+	matches := generationRegexp.FindStringSubmatch(extraSpecs[generationSpec])
+	if len(matches) > 1 {
+		if generation, err := strconv.Atoi(matches[1]); err == nil && generation >= kvmLatestGeneration {
+			return kvmLatestMaxDisks
+		}
+	}
+	return kvmMaxDisks
+}
+
+func getNodeMaxVolumesLabel(nodeName string) (int64, error) {
+	if nodeName == "" {
+		return 0, nil
+	}
+
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return 0, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return 0, err
+	}
+	node, err := client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	v, ok := node.Labels[maxVolumesLabel]
+	if !ok {
+		return 0, nil
+	}
+	maxVolumes, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || maxVolumes < 1 {
+		return 0, fmt.Errorf("expected a positive number, but got %s", v)
+	}
+	return maxVolumes, nil
+}
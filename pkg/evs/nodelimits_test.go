@@ -0,0 +1,67 @@
+package evs
+
+import "testing"
+
+func TestGetFlavorMaxDisks(t *testing.T) {
+	tests := []struct {
+		name        string
+		extraSpecs  map[string]string
+		expected    int
+		description string
+	}{
+		{
+			name:        "test1",
+			extraSpecs:  map[string]string{},
+			expected:    kvmMaxDisks,
+			description: "no extra specs",
+		},
+		{
+			name: "test2",
+			extraSpecs: map[string]string{
+				virtualizationTypeSpec: xenVirtualizationType,
+				generationSpec:         "s7",
+			},
+			expected:    xenMaxDisks,
+			description: "Xen instances are limited regardless of the generation",
+		},
+		{
+			name:        "test3",
+			extraSpecs:  map[string]string{virtualizationTypeSpec: "CloudCompute", generationSpec: "s6"},
+			expected:    kvmMaxDisks,
+			description: "KVM instances before the 7th generation",
+		},
+		{
+			name:        "test4",
+			extraSpecs:  map[string]string{virtualizationTypeSpec: "CloudCompute", generationSpec: "s7"},
+			expected:    kvmLatestMaxDisks,
+			description: "KVM instances of the 7th generation",
+		},
+		{
+			name:        "test5",
+			extraSpecs:  map[string]string{generationSpec: "c7n"},
+			expected:    kvmMaxDisks,
+			description: "the generation does not end with a number",
+		},
+		{
+			name:        "test6",
+			extraSpecs:  map[string]string{generationSpec: "ac8"},
+			expected:    kvmLatestMaxDisks,
+			description: "the generation after the 7th",
+		},
+		{
+			name:        "test7",
+			extraSpecs:  map[string]string{generationSpec: "m11"},
+			expected:    kvmLatestMaxDisks,
+			description: "the generation has multiple digits",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			maxDisks := getFlavorMaxDisks(testCase.extraSpecs)
+			if maxDisks != testCase.expected {
+				t.Errorf("expected: %d, got: %d", testCase.expected, maxDisks)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/chnsz/golangsdk/openstack/evs/v2/cloudvolumes"
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -13,6 +14,7 @@ import (
 	"golang.org/x/net/context"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog"
 	log "k8s.io/klog/v2"
 	mountutils "k8s.io/mount-utils"
@@ -21,15 +23,22 @@ import (
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils/blockdevice"
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils/metadatas"
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils/mounts"
 )
 
 const (
-	maxVolumes = 24 // the maximum number of volumes for a KVM instance is 24
+	defaultMaxVolumes = 24 // the maximum number of volumes for a KVM instance is 24
+
+	rescanInitDelay = 1 * time.Second
+	rescanFactor    = 1.5
+	rescanSteps     = 6
 )
 
 type nodeServer struct {
+	csi.UnimplementedNodeServer
+
 	Driver   *EvsDriver
 	Mount    mounts.IMount
 	Metadata metadatas.IMetadata
@@ -37,7 +46,7 @@ type nodeServer struct {
 
 func (ns *nodeServer) NodeStageVolume(_ context.Context, req *csi.NodeStageVolumeRequest) (
 	*csi.NodeStageVolumeResponse, error) {
-	log.Infof("NodeStageVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeStageVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	cc := ns.Driver.cloudCredentials
 	stagingTarget := req.GetStagingTargetPath()
@@ -57,12 +66,32 @@ func (ns *nodeServer) NodeStageVolume(_ context.Context, req *csi.NodeStageVolum
 		return nil, status.Errorf(codes.Internal, "Unable to find devicePath for volume: %v", err)
 	}
 
+	volumeContext := req.GetVolumeContext()
 	if blk := volumeCapability.GetBlock(); blk != nil {
-		// If block volume, do nothing
+		if isLuksEncrypted(volumeContext) {
+			return nil, status.Errorf(codes.InvalidArgument, "%s is not supported for the volumes in Block mode",
+				NodeEncryptionKey)
+		}
+		// The volume created from a snapshot or another volume may be larger than the source,
+		// make sure the kernel sees the full size, there is no file system to resize.
+		if vol.SourceVolID != "" || vol.SnapshotID != "" {
+			if _, err = rescanBlockDevice(devicePath, stagingTarget, int64(vol.Size*common.GbByteSize)); err != nil {
+				return nil, status.Errorf(codes.Internal, "Could not rescan the block device of volume %v: %v",
+					volumeID, err)
+			}
+		}
 		log.Infof("Volume mode is Block, skip staging volume")
 		return &csi.NodeStageVolumeResponse{}, nil
 	}
 
+	// The file system is created on the LUKS mapper device of the encrypted volume
+	if isLuksEncrypted(volumeContext) {
+		devicePath, err = openLuksDevice(cc, mount.Mounter(), volumeID, devicePath, volumeContext)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	notMnt, err := mount.IsLikelyNotMountPointAttach(stagingTarget)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
@@ -73,22 +102,38 @@ func (ns *nodeServer) NodeStageVolume(_ context.Context, req *csi.NodeStageVolum
 		// set default fstype is ext4
 		fsType := "ext4"
 		var options []string
+		volumeMountGroup := ""
 		if mnt := volumeCapability.GetMount(); mnt != nil {
 			if mnt.FsType != "" {
 				fsType = mnt.FsType
 			}
 			mountFlags := mnt.GetMountFlags()
 			options = append(options, collectMountOptions(fsType, mountFlags)...)
+			volumeMountGroup = mnt.GetVolumeMountGroup()
 		}
 		// Mount volume
-		err = mount.Mounter().FormatAndMount(devicePath, stagingTarget, fsType, options)
+		formatOptions := getFormatOptions(fsType, volumeContext)
+		err = mount.Mounter().FormatAndMountSensitiveWithFormatOptions(devicePath, stagingTarget, fsType, options,
+			nil, formatOptions)
 		if err != nil {
 			return nil, status.Error(codes.Internal, err.Error())
 		}
+		if err = tuneReservedBlocks(mount.Mounter().Exec, devicePath, fsType, volumeContext); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		// Apply the fsGroup at mount time, so kubelet does not need to change the ownership recursively
+		if err = applyVolumeMountGroup(stagingTarget, volumeMountGroup); err != nil {
+			return nil, status.Errorf(codes.Internal, "Failed to apply volume mount group: %v", err)
+		}
 	}
 
 	// Try expanding the volume if it's created from a snapshot or another volume (see #1539)
 	if vol.SourceVolID != "" || vol.SnapshotID != "" {
+		if isLuksEncrypted(volumeContext) {
+			if err = resizeLuksDevice(cc, mount.Mounter(), volumeID, volumeContext); err != nil {
+				return nil, err
+			}
+		}
 		r := mountutils.NewResizeFs(mount.Mounter().Exec)
 		needResize, err := r.NeedResize(devicePath, stagingTarget)
 		if err != nil {
@@ -160,7 +205,7 @@ func nodeStageValidation(cc *config.CloudCredentials, volumeID, target string, v
 }
 
 func (ns *nodeServer) NodeUnstageVolume(_ context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
-	log.Infof("NodeUnstageVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeUnstageVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	stagingTargetPath := req.GetStagingTargetPath()
@@ -173,6 +218,9 @@ func (ns *nodeServer) NodeUnstageVolume(_ context.Context, req *csi.NodeUnstageV
 		return nil, status.Errorf(codes.Internal, "Unmount of targetPath %s failed with error %v",
 			stagingTargetPath, err)
 	}
+	if err = closeLuksDevice(ns.Mount.Mounter(), volumeID); err != nil {
+		return nil, err
+	}
 
 	log.Infof("Successfully unstaged volume %v at path %s", volumeID, stagingTargetPath)
 	return &csi.NodeUnstageVolumeResponse{}, nil
@@ -194,7 +242,7 @@ func unstagetValidation(cc *config.CloudCredentials, volumeID, target string) er
 }
 
 func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
-	log.Infof("NodePublishVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodePublishVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	cc := ns.Driver.cloudCredentials
 	volumeID := req.GetVolumeId()
@@ -220,7 +268,19 @@ func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishV
 
 	if blk := volumeCapability.GetBlock(); blk != nil {
 		log.Infof("The volume mode is block")
-		return nodePublishVolumeForBlock(req, ns, mountOptions)
+		resp, err := nodePublishVolumeForBlock(req, ns, mountOptions)
+		if err != nil {
+			return nil, err
+		}
+		if isScsiReservationEnabled(req.GetVolumeContext()) {
+			if err = ns.registerReservation(volumeID); err != nil {
+				return nil, err
+			}
+		}
+		if err = ns.applyIOThrottle(volumeID, targetPath, req.GetVolumeContext()); err != nil {
+			return nil, err
+		}
+		return resp, nil
 	}
 
 	mount := ns.Mount
@@ -232,6 +292,17 @@ func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishV
 
 	// Volume Mount
 	if notMnt {
+		if isSingleWriter(volumeCapability) {
+			// The publish targets are bind mounted from the staging target
+			refs, err := mount.Mounter().GetMountRefs(source)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "Failed to get the mount references of %s: %v", source, err)
+			}
+			if err = checkPublishedElsewhere(volumeID, targetPath, refs); err != nil {
+				return nil, err
+			}
+		}
+
 		fsType := "ext4"
 		if mnt := volumeCapability.GetMount(); mnt != nil {
 			if mnt.FsType != "" {
@@ -245,10 +316,69 @@ func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishV
 		}
 	}
 
+	// The I/O limits are applied to the pod cgroup whether the target is just mounted or not
+	if err = ns.applyIOThrottle(volumeID, targetPath, req.GetVolumeContext()); err != nil {
+		return nil, err
+	}
+
 	log.Infof("Successfully publish volume on node, targetPath: %s", targetPath)
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+func isSingleWriter(vc *csi.VolumeCapability) bool {
+	return vc.GetAccessMode().GetMode() == csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER
+}
+
+// checkPublishedElsewhere refuses to publish the SINGLE_NODE_SINGLE_WRITER volume
+// when it's still published to another target path.
+func checkPublishedElsewhere(volumeID, targetPath string, refs []string) error {
+	for _, ref := range refs {
+		if ref != targetPath {
+			return status.Errorf(codes.FailedPrecondition, "Volume %s with access mode %s is already published "+
+				"to %s", volumeID, csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER, ref)
+		}
+	}
+	return nil
+}
+
+// excludeKubeletDeviceBind removes the bind mount made by kubelet from the publish target to
+// .../volumeDevices/<pv name>/dev/<pod uid>, which belongs to the same pod as the target path.
+func excludeKubeletDeviceBind(refs []string, targetPath string) []string {
+	podUID := filepath.Base(targetPath)
+	pvName := filepath.Base(filepath.Dir(targetPath))
+	devicePath := filepath.Join("volumeDevices", pvName, "dev", podUID)
+
+	var result []string
+	for _, ref := range refs {
+		if !strings.HasSuffix(ref, string(filepath.Separator)+devicePath) {
+			result = append(result, ref)
+		}
+	}
+	return result
+}
+
+// getBlockDeviceMountRefs returns the publish targets of the raw block device,
+// which are bind mounted from the device file of devtmpfs.
+func getBlockDeviceMountRefs(devicePath string) ([]string, error) {
+	realPath, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := mountutils.ParseMountInfo(procMountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []string
+	root := "/" + filepath.Base(realPath)
+	for _, info := range infos {
+		if info.FsType == "devtmpfs" && info.Root == root {
+			refs = append(refs, info.MountPoint)
+		}
+	}
+	return refs, nil
+}
+
 func nodePublishValidation(cc *config.CloudCredentials, volumeID, sourcePath, targetPath string, vc *csi.VolumeCapability) error {
 	if len(volumeID) == 0 {
 		return status.Error(codes.InvalidArgument, "Validation failed, volumeID cannot be empty")
@@ -272,7 +402,7 @@ func nodePublishValidation(cc *config.CloudCredentials, volumeID, sourcePath, ta
 
 func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (
 	*csi.NodeUnpublishVolumeResponse, error) {
-	log.Infof("NodeUnpublishVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeUnpublishVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	cc := ns.Driver.cloudCredentials
 	volumeID := req.GetVolumeId()
@@ -316,13 +446,22 @@ func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpu
 	if ephemeralVolume {
 		return nodeUnpublishEphemeral(ns, vol)
 	}
+	if err = ns.removeIOThrottle(volumeID, targetPath); err != nil {
+		log.Warningf("Failed to remove the I/O limits of volume %s: %v", volumeID, err)
+	}
+	// The reservation key is only registered on the volumes with scsiReservation enabled
+	if hasReservationMarker(volumeID) {
+		if err = ns.clearReservation(volumeID); err != nil {
+			log.Warningf("Failed to clear the reservation key of volume %s: %v", volumeID, err)
+		}
+	}
 
 	log.Infof("Successfully unpublish volume on node, targetPath: %s", targetPath)
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
 func (ns *nodeServer) NodeGetInfo(_ context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
-	log.Infof("NodeGetInfo called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetInfo called with request %v", protosanitizer.StripSecrets(req))
 
 	nodeID, err := ns.Metadata.GetInstanceID()
 	if err != nil {
@@ -338,13 +477,13 @@ func (ns *nodeServer) NodeGetInfo(_ context.Context, req *csi.NodeGetInfoRequest
 	return &csi.NodeGetInfoResponse{
 		NodeId:             nodeID,
 		AccessibleTopology: topology,
-		MaxVolumesPerNode:  maxVolumes,
+		MaxVolumesPerNode:  ns.Driver.getMaxVolumesPerNode(nodeID),
 	}, nil
 }
 
 func (ns *nodeServer) NodeGetCapabilities(_ context.Context, req *csi.NodeGetCapabilitiesRequest) (
 	*csi.NodeGetCapabilitiesResponse, error) {
-	log.Infof("NodeGetCapabilities called with req: %#v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetCapabilities called with req: %#v", protosanitizer.StripSecrets(req))
 
 	return &csi.NodeGetCapabilitiesResponse{
 		Capabilities: ns.Driver.nscap,
@@ -353,7 +492,7 @@ func (ns *nodeServer) NodeGetCapabilities(_ context.Context, req *csi.NodeGetCap
 
 func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeStatsRequest) (
 	*csi.NodeGetVolumeStatsResponse, error) {
-	log.Infof("NodeGetVolumeStats: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetVolumeStats: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	volumePath := req.GetVolumePath()
@@ -361,8 +500,20 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 		return nil, err
 	}
 
+	condition := checkNodeVolumeCondition(volumePath)
 	stats, err := ns.Mount.GetDeviceStats(volumePath)
 	if err != nil {
+		// The usage is unknown when the volume is abnormal, e.g. the device is missing, it's reported as zero
+		// since kubelet requires the usage in the response.
+		if condition.Abnormal {
+			return &csi.NodeGetVolumeStatsResponse{
+				Usage: []*csi.VolumeUsage{
+					{Unit: csi.VolumeUsage_BYTES},
+					{Unit: csi.VolumeUsage_INODES},
+				},
+				VolumeCondition: condition,
+			}, nil
+		}
 		return nil, status.Errorf(codes.Unknown, "Failed to get stats by path: %s", err)
 	}
 	if stats.Block {
@@ -373,6 +524,7 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 					Unit:  csi.VolumeUsage_BYTES,
 				},
 			},
+			VolumeCondition: condition,
 		}, nil
 	}
 
@@ -381,6 +533,7 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 			{Total: stats.TotalBytes, Available: stats.AvailableBytes, Used: stats.UsedBytes, Unit: csi.VolumeUsage_BYTES},
 			{Total: stats.TotalInodes, Available: stats.AvailableInodes, Used: stats.UsedInodes, Unit: csi.VolumeUsage_INODES},
 		},
+		VolumeCondition: condition,
 	}, nil
 }
 
@@ -405,7 +558,7 @@ func nodeGetStatsValidation(volumeID, volumePath string) error {
 
 func (ns *nodeServer) NodeExpandVolume(_ context.Context, req *csi.NodeExpandVolumeRequest) (
 	*csi.NodeExpandVolumeResponse, error) {
-	log.Infof("NodeExpandVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeExpandVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	volumePath := req.GetVolumePath()
@@ -414,6 +567,18 @@ func (ns *nodeServer) NodeExpandVolume(_ context.Context, req *csi.NodeExpandVol
 		return nil, err
 	}
 
+	isBlock := req.GetVolumeCapability().GetBlock() != nil
+	if !isBlock {
+		var err error
+		if isBlock, err = blockdevice.IsBlockDevice(volumePath); err != nil {
+			return nil, status.Errorf(codes.NotFound, "Failed to determine whether %s is a block device: %v",
+				volumePath, err)
+		}
+	}
+	if isBlock {
+		return ns.nodeExpandVolumeForBlock(volumeID, volumePath, req.GetCapacityRange().GetRequiredBytes())
+	}
+
 	output, err := ns.Mount.GetMountFs(volumePath)
 	if err != nil {
 		return nil, status.Errorf(codes.Unknown, "Failed to find mount file system %s: %v", volumePath, err)
@@ -424,6 +589,13 @@ func (ns *nodeServer) NodeExpandVolume(_ context.Context, req *csi.NodeExpandVol
 		return nil, status.Errorf(codes.Unknown, "Unable to find device path for volume: %s", output)
 	}
 
+	// The LUKS mapper device is resized to the new size of the volume before the file system
+	if isLuksOpened(volumeID) {
+		if err = resizeLuksDevice(ns.Driver.cloudCredentials, ns.Mount.Mounter(), volumeID, nil); err != nil {
+			return nil, err
+		}
+	}
+
 	r := mountutils.NewResizeFs(ns.Mount.Mounter().Exec)
 	if _, err = r.Resize(devicePath, volumePath); err != nil {
 		return nil, status.Errorf(codes.Unknown, "Could not resize volume %q:  %v", volumeID, err)
@@ -431,6 +603,46 @@ func (ns *nodeServer) NodeExpandVolume(_ context.Context, req *csi.NodeExpandVol
 	return &csi.NodeExpandVolumeResponse{}, nil
 }
 
+// nodeExpandVolumeForBlock rescans the device of the raw block volume so that the kernel sees the new size,
+// there is no file system to resize.
+func (ns *nodeServer) nodeExpandVolumeForBlock(volumeID, volumePath string, requiredBytes int64) (
+	*csi.NodeExpandVolumeResponse, error) {
+	devicePath, err := getDevicePath(ns.Driver.cloudCredentials, volumeID, ns.Mount)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Unable to find devicePath for volume: %v", err)
+	}
+
+	size, err := rescanBlockDevice(devicePath, volumePath, requiredBytes)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Could not rescan the block device of volume %q: %v",
+			volumeID, err)
+	}
+	log.Infof("Successfully expanded block volume %s to %d bytes", volumeID, size)
+	return &csi.NodeExpandVolumeResponse{CapacityBytes: size}, nil
+}
+
+// rescanBlockDevice rescans the SCSI device until the kernel sees the new size, the capacity change of
+// the virtio devices is notified by the hypervisor, which may take a moment.
+func rescanBlockDevice(devicePath, volumePath string, requiredBytes int64) (int64, error) {
+	backoff := wait.Backoff{
+		Duration: rescanInitDelay,
+		Factor:   rescanFactor,
+		Steps:    rescanSteps,
+	}
+	var rescanErr error
+	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		rescanErr = blockdevice.RescanBlockDeviceGeometry(devicePath, volumePath, requiredBytes)
+		return rescanErr == nil, nil
+	})
+	if err != nil {
+		if rescanErr != nil {
+			return 0, rescanErr
+		}
+		return 0, err
+	}
+	return blockdevice.GetBlockDeviceSize(devicePath)
+}
+
 func nodeExpendValidation(cc *config.CloudCredentials, volumeID, volumePath string) error {
 	if len(volumeID) == 0 {
 		return status.Error(codes.InvalidArgument, "Validation failed, VolumeID not provided")
@@ -486,7 +698,7 @@ func nodeUnpublishEphemeral(ns *nodeServer, vol *cloudvolumes.Volume) (*csi.Node
 }
 
 func nodePublishEphemeral(req *csi.NodePublishVolumeRequest, ns *nodeServer) (*csi.NodePublishVolumeResponse, error) {
-	log.Infof("nodePublishEphemeral: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("nodePublishEphemeral: called with args %v", protosanitizer.StripSecrets(req))
 
 	cc := ns.Driver.cloudCredentials
 	size := 10 // default size is 1GB
@@ -581,7 +793,7 @@ func nodePublishEphemeral(req *csi.NodePublishVolumeRequest, ns *nodeServer) (*c
 
 func nodePublishVolumeForBlock(req *csi.NodePublishVolumeRequest, ns *nodeServer, mountOptions []string) (
 	*csi.NodePublishVolumeResponse, error) {
-	log.Infof("nodePublishVolumeForBlock: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("nodePublishVolumeForBlock: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	targetPath := req.GetTargetPath()
@@ -595,6 +807,22 @@ func nodePublishVolumeForBlock(req *csi.NodePublishVolumeRequest, ns *nodeServer
 		return nil, status.Errorf(codes.Internal, "Error query devicePath for volume %s: %v", volumeID, err)
 	}
 
+	refs, err := getBlockDeviceMountRefs(source)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to get the mount references of %s: %v", source, err)
+	}
+	for _, ref := range refs {
+		if ref == targetPath {
+			log.Infof("Volume %s is already published to %s", volumeID, targetPath)
+			return &csi.NodePublishVolumeResponse{}, nil
+		}
+	}
+	if isSingleWriter(req.GetVolumeCapability()) {
+		if err = checkPublishedElsewhere(volumeID, targetPath, excludeKubeletDeviceBind(refs, targetPath)); err != nil {
+			return nil, err
+		}
+	}
+
 	exists, err := utilpath.Exists(utilpath.CheckFollowSymlink, podVolumePath)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
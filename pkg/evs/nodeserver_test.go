@@ -0,0 +1,107 @@
+package evs
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCheckPublishedElsewhere(t *testing.T) {
+	targetPath := "/var/lib/kubelet/pods/pod-1/volumes/kubernetes.io~csi/pv-name/mount"
+
+	tests := []struct {
+		name        string
+		refs        []string
+		code        codes.Code
+		description string
+	}{
+		{
+			name:        "test1",
+			refs:        nil,
+			description: "the volume is not published",
+		},
+		{
+			name:        "test2",
+			refs:        []string{targetPath},
+			description: "the volume is only published to the target path",
+		},
+		{
+			name: "test3",
+			refs: []string{
+				targetPath,
+				"/var/lib/kubelet/pods/pod-2/volumes/kubernetes.io~csi/pv-name/mount",
+			},
+			code:        codes.FailedPrecondition,
+			description: "the volume is also published to another pod",
+		},
+		{
+			name:        "test4",
+			refs:        []string{"/var/lib/kubelet/pods/pod-2/volumes/kubernetes.io~csi/pv-name/mount"},
+			code:        codes.FailedPrecondition,
+			description: "the volume is only published to another pod",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			err := checkPublishedElsewhere("volume-id", targetPath, testCase.refs)
+			if status.Code(err) != testCase.code {
+				t.Errorf("expected code: %v, got error: %v", testCase.code, err)
+			}
+		})
+	}
+}
+
+func TestExcludeKubeletDeviceBind(t *testing.T) {
+	targetPath := "/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/publish/pv-name/pod-1"
+
+	tests := []struct {
+		name        string
+		refs        []string
+		expected    []string
+		description string
+	}{
+		{
+			name: "test1",
+			refs: []string{
+				targetPath,
+				"/var/lib/kubelet/pods/pod-1/volumeDevices/kubernetes.io~csi/pv-name",
+				"/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/pv-name/dev/pod-1",
+			},
+			expected: []string{
+				targetPath,
+				"/var/lib/kubelet/pods/pod-1/volumeDevices/kubernetes.io~csi/pv-name",
+			},
+			description: "the device bind of the same pod is removed",
+		},
+		{
+			name: "test2",
+			refs: []string{
+				"/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/pv-name/dev/pod-2",
+				"/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/other-pv/dev/pod-1",
+			},
+			expected: []string{
+				"/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/pv-name/dev/pod-2",
+				"/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/other-pv/dev/pod-1",
+			},
+			description: "the device binds of the other pods or volumes are kept",
+		},
+		{
+			name:        "test3",
+			refs:        []string{"/var/lib/kubelet/plugins/kubernetes.io/csi/volumeDevices/pv-name/dev/pod-1"},
+			expected:    nil,
+			description: "only the device bind of the same pod",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			refs := excludeKubeletDeviceBind(testCase.refs, targetPath)
+			if !reflect.DeepEqual(refs, testCase.expected) {
+				t.Errorf("expected: %v, got: %v", testCase.expected, refs)
+			}
+		})
+	}
+}
@@ -0,0 +1,513 @@
+package evs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+	obsservices "github.com/huaweicloud/huaweicloud-csi-driver/pkg/obs/services"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils/metadatas"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils/mounts"
+)
+
+const (
+	// ObsObjectSource is the custom resource referenced by the dataSourceRef of the PVCs,
+	// the volumes of the PVCs are populated from the OBS object.
+	populatorKind     = "ObsObjectSource"
+	populatorResource = "obsobjectsources"
+	populatorVersion  = "v1alpha1"
+
+	populatorResyncPeriod = 30 * time.Second
+
+	objectFormatRaw   = "raw"
+	objectFormatQcow2 = "qcow2"
+
+	fsTypeParameter         = "csi.storage.k8s.io/fstype"
+	provisionedByAnnotation = "pv.kubernetes.io/provisioned-by"
+	selectedNodeAnnotation  = "volume.kubernetes.io/selected-node"
+
+	// the component and the reasons of the events of the PVCs
+	populatorComponent    = "evs-obs-populator"
+	populateFailedReason  = "PopulateFailed"
+	populateSucceedReason = "Populated"
+)
+
+var populatorGVR = schema.GroupVersionResource{
+	Group:    driverName,
+	Version:  populatorVersion,
+	Resource: populatorResource,
+}
+
+// obsObjectSource is the spec of the ObsObjectSource
+type obsObjectSource struct {
+	Bucket string
+	Object string
+	Format string
+}
+
+// obsPopulator populates the EVS volumes from the OBS objects, the volumes are attached to the instance of
+// the populator temporarily to write the objects, then the PVs are created and bound to the PVCs.
+type obsPopulator struct {
+	Driver *EvsDriver
+	Mount  mounts.IMount
+
+	client           kubernetes.Interface
+	dynamicClient    dynamic.Interface
+	instanceID       string
+	availabilityZone string
+
+	// the last errors of the PVCs, the event is only reported when the error changes
+	lastErrors map[types.UID]string
+}
+
+func newObsPopulator(d *EvsDriver, mount mounts.IMount, metadata metadatas.IMetadata) (*obsPopulator, error) {
+	restConfig, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	instanceID, err := metadata.GetInstanceID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the instance ID of the populator: %v", err)
+	}
+	az, err := metadata.GetAvailabilityZone()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the availability zone of the populator: %v", err)
+	}
+
+	return &obsPopulator{
+		Driver:           d,
+		Mount:            mount,
+		client:           client,
+		dynamicClient:    dynamicClient,
+		instanceID:       instanceID,
+		availabilityZone: az,
+		lastErrors:       map[types.UID]string{},
+	}, nil
+}
+
+func (p *obsPopulator) run(stopCh <-chan struct{}) {
+	log.Infof("Start the OBS populator on instance %s in availability zone %s", p.instanceID, p.availabilityZone)
+	wait.Until(p.reconcile, populatorResyncPeriod, stopCh)
+}
+
+func (p *obsPopulator) reconcile() {
+	pvcs, err := p.client.CoreV1().PersistentVolumeClaims("").List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("Failed to list PVCs: %v", err)
+		return
+	}
+
+	pending := make(map[types.UID]bool)
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if !isObsObjectSourcePending(pvc) {
+			continue
+		}
+		pending[pvc.UID] = true
+		if err = p.populate(pvc); err != nil {
+			log.Errorf("Failed to populate PVC %s/%s: %v", pvc.Namespace, pvc.Name, err)
+			if p.lastErrors[pvc.UID] != err.Error() {
+				p.lastErrors[pvc.UID] = err.Error()
+				p.recordEvent(pvc, corev1.EventTypeWarning, populateFailedReason, err.Error())
+			}
+		}
+	}
+	for uid := range p.lastErrors {
+		if !pending[uid] {
+			delete(p.lastErrors, uid)
+		}
+	}
+}
+
+// recordEvent reports the event of the PVC, the failure of reporting is only logged
+func (p *obsPopulator) recordEvent(pvc *corev1.PersistentVolumeClaim, eventType, reason, message string) {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: pvc.Name + ".",
+			Namespace:    pvc.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:            "PersistentVolumeClaim",
+			APIVersion:      "v1",
+			Namespace:       pvc.Namespace,
+			Name:            pvc.Name,
+			UID:             pvc.UID,
+			ResourceVersion: pvc.ResourceVersion,
+		},
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: populatorComponent},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+	}
+	_, err := p.client.CoreV1().Events(pvc.Namespace).Create(context.TODO(), event, metav1.CreateOptions{})
+	if err != nil {
+		log.Warningf("Failed to report the event %s of PVC %s/%s: %v", reason, pvc.Namespace, pvc.Name, err)
+	}
+}
+
+func isObsObjectSourcePending(pvc *corev1.PersistentVolumeClaim) bool {
+	ref := pvc.Spec.DataSourceRef
+	if ref == nil || ref.APIGroup == nil || *ref.APIGroup != driverName || ref.Kind != populatorKind {
+		return false
+	}
+	return pvc.Spec.VolumeName == "" && pvc.Status.Phase == corev1.ClaimPending
+}
+
+func (p *obsPopulator) populate(pvc *corev1.PersistentVolumeClaim) error {
+	if pvc.Spec.StorageClassName == nil {
+		return fmt.Errorf("the storage class of the PVC is not specified")
+	}
+	sc, err := p.client.StorageV1().StorageClasses().Get(context.TODO(), *pvc.Spec.StorageClassName,
+		metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if sc.Provisioner != driverName {
+		return nil
+	}
+
+	pvName := "pvc-" + string(pvc.UID)
+	if _, err = p.client.CoreV1().PersistentVolumes().Get(context.TODO(), pvName, metav1.GetOptions{}); err == nil {
+		return nil
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	if err = p.checkSelectedNode(pvc); err != nil {
+		return err
+	}
+	source, err := p.getObsObjectSource(pvc.Namespace, pvc.Spec.DataSourceRef.Name)
+	if err != nil {
+		return err
+	}
+
+	volume, err := p.createVolume(pvName, pvc, sc.Parameters)
+	if err != nil {
+		return err
+	}
+	metadata, err := services.GetVolumeMetadata(p.Driver.cloudCredentials, volume.VolumeId)
+	if err != nil {
+		return err
+	}
+	if _, ok := metadata[PopulatedFromKey]; !ok {
+		if err = p.writeVolume(volume.VolumeId, source); err != nil {
+			return err
+		}
+		populatedFrom := map[string]string{
+			PopulatedFromKey: fmt.Sprintf("obs://%s/%s", source.Bucket, source.Object),
+		}
+		err = services.UpdateVolumeMetadata(p.Driver.cloudCredentials, volume.VolumeId, populatedFrom)
+		if err != nil {
+			return err
+		}
+	}
+
+	pv := buildPopulatedPV(pvName, pvc, sc.Parameters[fsTypeParameter], volume, p.availabilityZone)
+	if sc.ReclaimPolicy != nil {
+		pv.Spec.PersistentVolumeReclaimPolicy = *sc.ReclaimPolicy
+	}
+	pv.Spec.StorageClassName = sc.Name
+	pv.Spec.MountOptions = sc.MountOptions
+	if _, err = p.client.CoreV1().PersistentVolumes().Create(context.TODO(), pv, metav1.CreateOptions{}); err != nil {
+		return err
+	}
+	log.Infof("Successfully populated volume %s of PVC %s/%s from OBS object %s/%s",
+		volume.VolumeId, pvc.Namespace, pvc.Name, source.Bucket, source.Object)
+	p.recordEvent(pvc, corev1.EventTypeNormal, populateSucceedReason, fmt.Sprintf(
+		"Successfully populated volume %s from OBS object %s/%s", volume.VolumeId, source.Bucket, source.Object))
+	return nil
+}
+
+// checkSelectedNode refuses the PVCs of the WaitForFirstConsumer storage classes whose selected node is in
+// another availability zone, since the volume is always created in the availability zone of the populator.
+func (p *obsPopulator) checkSelectedNode(pvc *corev1.PersistentVolumeClaim) error {
+	nodeName := pvc.Annotations[selectedNodeAnnotation]
+	if nodeName == "" {
+		return nil
+	}
+	node, err := p.client.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	az := node.Labels[topologyKey]
+	if az == "" {
+		az = node.Labels[corev1.LabelTopologyZone]
+	}
+	if az != "" && az != p.availabilityZone {
+		return fmt.Errorf("the selected node %s is in availability zone %s, which is different from the "+
+			"availability zone %s of the populator", nodeName, az, p.availabilityZone)
+	}
+	return nil
+}
+
+func (p *obsPopulator) getObsObjectSource(namespace, name string) (*obsObjectSource, error) {
+	obj, err := p.dynamicClient.Resource(populatorGVR).Namespace(namespace).Get(context.TODO(), name,
+		metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	source := &obsObjectSource{}
+	source.Bucket, _, _ = unstructured.NestedString(obj.Object, "spec", "bucket")
+	source.Object, _, _ = unstructured.NestedString(obj.Object, "spec", "object")
+	source.Format, _, _ = unstructured.NestedString(obj.Object, "spec", "format")
+	if source.Bucket == "" || source.Object == "" {
+		return nil, fmt.Errorf("the bucket and the object of %s %s/%s cannot be empty", populatorKind, namespace, name)
+	}
+	switch source.Format {
+	case "":
+		source.Format = objectFormatRaw
+	case objectFormatRaw, objectFormatQcow2:
+	default:
+		return nil, fmt.Errorf("the format of %s %s/%s error, expected raw or qcow2, but got %s",
+			populatorKind, namespace, name, source.Format)
+	}
+	return source, nil
+}
+
+// createVolume creates the volume through the controller server, the volume is created in the availability zone
+// of the populator so that it can be attached to the instance of the populator.
+func (p *obsPopulator) createVolume(name string, pvc *corev1.PersistentVolumeClaim, scParameters map[string]string) (
+	*csi.Volume, error) {
+	if az := scParameters["availability"]; az != "" && az != p.availabilityZone {
+		return nil, fmt.Errorf("the availability zone %s of the storage class is different from the "+
+			"availability zone %s of the populator", az, p.availabilityZone)
+	}
+
+	parameters := map[string]string{
+		PvcNameTag: pvc.Name,
+		PvcNsTag:   pvc.Namespace,
+		PvNameKey:  name,
+	}
+	for k, v := range scParameters {
+		parameters[k] = v
+	}
+	capabilities, err := getPVCVolumeCapabilities(pvc, scParameters[fsTypeParameter])
+	if err != nil {
+		return nil, err
+	}
+	topology := []*csi.Topology{{Segments: map[string]string{topologyKey: p.availabilityZone}}}
+
+	storage := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	req := &csi.CreateVolumeRequest{
+		Name:               name,
+		CapacityRange:      &csi.CapacityRange{RequiredBytes: storage.Value()},
+		VolumeCapabilities: capabilities,
+		Parameters:         parameters,
+		AccessibilityRequirements: &csi.TopologyRequirement{
+			Requisite: topology,
+			Preferred: topology,
+		},
+	}
+	rsp, err := p.Driver.cs.CreateVolume(context.TODO(), req)
+	if err != nil {
+		return nil, err
+	}
+	return rsp.GetVolume(), nil
+}
+
+func getPVCVolumeCapabilities(pvc *corev1.PersistentVolumeClaim, fsType string) ([]*csi.VolumeCapability, error) {
+	capability := &csi.VolumeCapability{
+		AccessType: &csi.VolumeCapability_Mount{
+			Mount: &csi.VolumeCapability_MountVolume{FsType: fsType},
+		},
+	}
+	if pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock {
+		capability.AccessType = &csi.VolumeCapability_Block{Block: &csi.VolumeCapability_BlockVolume{}}
+	}
+
+	capabilities := make([]*csi.VolumeCapability, 0, len(pvc.Spec.AccessModes))
+	for _, accessMode := range pvc.Spec.AccessModes {
+		var mode csi.VolumeCapability_AccessMode_Mode
+		switch accessMode {
+		case corev1.ReadWriteOnce:
+			mode = csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER
+		case corev1.ReadWriteOncePod:
+			mode = csi.VolumeCapability_AccessMode_SINGLE_NODE_SINGLE_WRITER
+		case corev1.ReadWriteMany:
+			mode = csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER
+		default:
+			return nil, fmt.Errorf("the access mode %s is not supported", accessMode)
+		}
+		capabilities = append(capabilities, &csi.VolumeCapability{
+			AccessType: capability.AccessType,
+			AccessMode: &csi.VolumeCapability_AccessMode{Mode: mode},
+		})
+	}
+	return capabilities, nil
+}
+
+// writeVolume attaches the volume to the instance of the populator, and writes the object to the device
+func (p *obsPopulator) writeVolume(volumeID string, source *obsObjectSource) error {
+	cc := p.Driver.cloudCredentials
+	vol, err := services.GetVolume(cc, volumeID)
+	if err != nil {
+		return err
+	}
+	switch volumeAttachmentStatus(vol, p.instanceID) {
+	case VolumeNotAttached:
+		if err = services.AttachVolumeCompleted(cc, p.instanceID, volumeID); err != nil {
+			return err
+		}
+	case VolumeAttachingCurrentServer:
+		if err = services.WaitForVolumeAttaching(cc, volumeID); err != nil {
+			return err
+		}
+	case VolumeAttachedCurrentServer:
+	default:
+		return fmt.Errorf("volume %s is attached to another server, status: %s", volumeID, vol.Status)
+	}
+
+	devicePath, err := getDevicePath(cc, volumeID, p.Mount)
+	if err == nil {
+		err = p.writeObject(devicePath, int64(vol.Size*common.GbByteSize), source)
+	}
+	if detachErr := services.DetachVolumeCompleted(cc, p.instanceID, volumeID); detachErr != nil {
+		log.Errorf("Failed to detach volume %s from the populator: %v", volumeID, detachErr)
+		if err == nil {
+			err = detachErr
+		}
+	}
+	return err
+}
+
+func (p *obsPopulator) writeObject(devicePath string, sizeBytes int64, source *obsObjectSource) error {
+	output, err := obsservices.GetObject(p.Driver.cloudCredentials, source.Bucket, source.Object)
+	if err != nil {
+		return err
+	}
+	defer output.Body.Close()
+
+	if source.Format == objectFormatRaw {
+		if output.ContentLength > sizeBytes {
+			return fmt.Errorf("the size %d of the object is larger than the size %d of the volume",
+				output.ContentLength, sizeBytes)
+		}
+		log.Infof("Write the raw object %s/%s to %s", source.Bucket, source.Object, devicePath)
+		return copyToFile(devicePath, output.Body)
+	}
+
+	// The qcow2 image is downloaded to a temporary file, and converted to the device
+	tmp, err := os.CreateTemp("", "obs-populator-*.qcow2")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = io.Copy(tmp, output.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	log.Infof("Convert the qcow2 object %s/%s to %s", source.Bucket, source.Object, devicePath)
+	args := []string{"convert", "-n", "-f", objectFormatQcow2, "-O", objectFormatRaw, tmp.Name(), devicePath}
+	if out, err := p.Mount.Mounter().Exec.Command("qemu-img", args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to convert the qcow2 image: %v, output: %s", err, string(out))
+	}
+	return nil
+}
+
+func copyToFile(path string, r io.Reader) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = io.Copy(f, r); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// buildPopulatedPV builds the PV of the populated volume, the PV is restricted to the availability zones of
+// the volume, or the availability zone of the populator if the volume does not report its topology.
+func buildPopulatedPV(name string, pvc *corev1.PersistentVolumeClaim, fsType string, volume *csi.Volume,
+	defaultZone string) *corev1.PersistentVolume {
+	var zones []string
+	for _, topology := range volume.GetAccessibleTopology() {
+		if zone := topology.GetSegments()[topologyKey]; zone != "" {
+			zones = append(zones, zone)
+		}
+	}
+	if len(zones) == 0 {
+		zones = []string{defaultZone}
+	}
+
+	return &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{provisionedByAnnotation: driverName},
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: *resource.NewQuantity(volume.GetCapacityBytes(), resource.BinarySI),
+			},
+			AccessModes:                   pvc.Spec.AccessModes,
+			VolumeMode:                    pvc.Spec.VolumeMode,
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimDelete,
+			ClaimRef: &corev1.ObjectReference{
+				Kind:       "PersistentVolumeClaim",
+				APIVersion: "v1",
+				Namespace:  pvc.Namespace,
+				Name:       pvc.Name,
+				UID:        pvc.UID,
+			},
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				CSI: &corev1.CSIPersistentVolumeSource{
+					Driver:           driverName,
+					VolumeHandle:     volume.GetVolumeId(),
+					FSType:           fsType,
+					VolumeAttributes: volume.GetVolumeContext(),
+				},
+			},
+			NodeAffinity: &corev1.VolumeNodeAffinity{
+				Required: &corev1.NodeSelector{
+					NodeSelectorTerms: []corev1.NodeSelectorTerm{
+						{
+							MatchExpressions: []corev1.NodeSelectorRequirement{
+								{
+									Key:      topologyKey,
+									Operator: corev1.NodeSelectorOpIn,
+									Values:   zones,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package evs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBuildPopulatedPVZones(t *testing.T) {
+	tests := []struct {
+		name        string
+		topology    []*csi.Topology
+		expected    []string
+		description string
+	}{
+		{
+			name:        "test1",
+			topology:    []*csi.Topology{{Segments: map[string]string{topologyKey: "az-2"}}},
+			expected:    []string{"az-2"},
+			description: "the availability zone of the volume",
+		},
+		{
+			name:        "test2",
+			topology:    nil,
+			expected:    []string{"az-1"},
+			description: "the volume does not report its topology",
+		},
+		{
+			name:        "test3",
+			topology:    []*csi.Topology{{Segments: map[string]string{}}},
+			expected:    []string{"az-1"},
+			description: "the topology of the volume does not contain the availability zone",
+		},
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc", Namespace: "default", UID: "uid"},
+	}
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			volume := &csi.Volume{VolumeId: "volume-id", AccessibleTopology: testCase.topology}
+			pv := buildPopulatedPV("pvc-uid", pvc, "ext4", volume, "az-1")
+			zones := pv.Spec.NodeAffinity.Required.NodeSelectorTerms[0].MatchExpressions[0].Values
+			if !reflect.DeepEqual(zones, testCase.expected) {
+				t.Errorf("expected: %v, got: %v", testCase.expected, zones)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package evs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+	utilexec "k8s.io/utils/exec"
+)
+
+// ScsiReservationKey in StorageClass parameters and volume context, the node registers its SCSI-3 persistent
+// reservation key on the shared SCSI disks when publishing, and clears it when the disk is no longer published.
+const ScsiReservationKey = "scsiReservation"
+
+// reservationDir keeps a marker file for each volume whose reservation key is registered by the node,
+// so that the key is only cleared for the volumes which opted in.
+var reservationDir = filepath.Join("/var/lib/kubelet/plugins", driverName, "reservations")
+
+// parseScsiReservation validates the scsiReservation parameter and adds it to the volume context,
+// the persistent reservations are only supported by the shared disks in SCSI mode.
+func parseScsiReservation(parameters, volumeContext map[string]string, multiattach bool) error {
+	v, ok := parameters[ScsiReservationKey]
+	if !ok {
+		return nil
+	}
+	enabled, err := strconv.ParseBool(v)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "%s error, expected a boolean, but got %s",
+			ScsiReservationKey, v)
+	}
+	if !enabled {
+		return nil
+	}
+	if parameters["scsi"] != "true" || !multiattach {
+		return status.Errorf(codes.InvalidArgument, "%s requires the shareable disks in SCSI mode, "+
+			"scsi and multiattach must be true", ScsiReservationKey)
+	}
+	volumeContext[ScsiReservationKey] = "true"
+	return nil
+}
+
+func isScsiReservationEnabled(volumeContext map[string]string) bool {
+	return volumeContext[ScsiReservationKey] == "true"
+}
+
+// getReservationKey returns the reservation key of the node, which is the first 8 bytes of the SHA-256 of
+// the ECS instance ID, so that the fencing tools can tell the nodes apart.
+func getReservationKey(instanceID string) string {
+	sum := sha256.Sum256([]byte(instanceID))
+	return "0x" + hex.EncodeToString(sum[:8])
+}
+
+// registerReservationKey registers the key of the node on the device, it's a no-op if it's already registered
+func registerReservationKey(exec utilexec.Interface, devicePath, key string) error {
+	output, err := exec.Command("sg_persist", "--out", "--no-inquiry", "--register-ignore",
+		"--param-sark="+key, devicePath).CombinedOutput()
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to register the reservation key %s on %s: %v, output: %s",
+			key, devicePath, err, string(output))
+	}
+	log.Infof("Successfully registered the reservation key %s on %s", key, devicePath)
+	return nil
+}
+
+// unregisterReservationKey clears the key of the node from the device, the reservation held by the key
+// is released as well. It's ignored if the key is not registered.
+func unregisterReservationKey(exec utilexec.Interface, devicePath, key string) error {
+	registered, err := isReservationKeyRegistered(exec, devicePath, key)
+	if err != nil || !registered {
+		return err
+	}
+
+	output, err := exec.Command("sg_persist", "--out", "--no-inquiry", "--register",
+		"--param-rk="+key, "--param-sark=0", devicePath).CombinedOutput()
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to clear the reservation key %s on %s: %v, output: %s",
+			key, devicePath, err, string(output))
+	}
+	log.Infof("Successfully cleared the reservation key %s on %s", key, devicePath)
+	return nil
+}
+
+func isReservationKeyRegistered(exec utilexec.Interface, devicePath, key string) (bool, error) {
+	output, err := exec.Command("sg_persist", "--in", "--no-inquiry", "--read-keys", devicePath).CombinedOutput()
+	if err != nil {
+		return false, status.Errorf(codes.Internal, "Failed to read the reservation keys on %s: %v, output: %s",
+			devicePath, err, string(output))
+	}
+	// The keys are printed in hex without the leading zeros
+	want, _ := strconv.ParseUint(strings.TrimPrefix(key, "0x"), 16, 64)
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "0x") {
+			continue
+		}
+		if got, err := strconv.ParseUint(strings.TrimPrefix(line, "0x"), 16, 64); err == nil && got == want {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func getReservationMarker(volumeID string) string {
+	return filepath.Join(reservationDir, volumeID)
+}
+
+// hasReservationMarker returns whether the reservation key of the node may be registered on the volume
+func hasReservationMarker(volumeID string) bool {
+	_, err := os.Stat(getReservationMarker(volumeID))
+	return err == nil
+}
+
+// registerReservation registers the reservation key of the node on the shared SCSI disk, the marker is written
+// before the key is registered, so that the key is never left behind.
+func (ns *nodeServer) registerReservation(volumeID string) error {
+	if err := os.MkdirAll(reservationDir, 0700); err != nil {
+		return status.Errorf(codes.Internal, "Failed to create %s: %v", reservationDir, err)
+	}
+	if err := os.WriteFile(getReservationMarker(volumeID), nil, 0600); err != nil {
+		return status.Errorf(codes.Internal, "Failed to write the reservation marker of volume %s: %v",
+			volumeID, err)
+	}
+
+	instanceID, err := ns.Metadata.GetInstanceID()
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to get the ECS instance ID: %v", err)
+	}
+	// The SCSI disks are discovered by WWN
+	devicePath, err := getDevicePath(ns.Driver.cloudCredentials, volumeID, ns.Mount)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Unable to find devicePath for volume: %v", err)
+	}
+	return registerReservationKey(ns.Mount.Mounter().Exec, devicePath, getReservationKey(instanceID))
+}
+
+// clearReservation clears the reservation key of the node when the disk is no longer published on the node
+func (ns *nodeServer) clearReservation(volumeID string) error {
+	devicePath, err := getDevicePath(ns.Driver.cloudCredentials, volumeID, ns.Mount)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Unable to find devicePath for volume: %v", err)
+	}
+	refs, err := getBlockDeviceMountRefs(devicePath)
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to get the mount references of %s: %v", devicePath, err)
+	}
+	if len(refs) > 0 {
+		log.Infof("Volume %s is still published to %v, keep the reservation key", volumeID, refs)
+		return nil
+	}
+
+	instanceID, err := ns.Metadata.GetInstanceID()
+	if err != nil {
+		return status.Errorf(codes.Internal, "Failed to get the ECS instance ID: %v", err)
+	}
+	if err = unregisterReservationKey(ns.Mount.Mounter().Exec, devicePath, getReservationKey(instanceID)); err != nil {
+		return err
+	}
+	if err = os.Remove(getReservationMarker(volumeID)); err != nil && !os.IsNotExist(err) {
+		return status.Errorf(codes.Internal, "Failed to remove the reservation marker of volume %s: %v",
+			volumeID, err)
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/chnsz/golangsdk"
+	"github.com/chnsz/golangsdk/openstack/cbr/v3/backups"
+	"github.com/chnsz/golangsdk/openstack/cbr/v3/vaults"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+const (
+	BackupAvailableStatus = "available"
+	BackupErrorStatus     = "error"
+
+	// volumeResourceType is the CBR resource type of EVS volumes
+	volumeResourceType = "OS::Cinder::Volume"
+)
+
+// ListBackupsOpts filters the CBR backups of EVS volumes
+type ListBackupsOpts struct {
+	Name         string `q:"name"`
+	VaultID      string `q:"vault_id"`
+	ResourceID   string `q:"resource_id"`
+	ResourceType string `q:"resource_type"`
+	CheckpointID string `q:"checkpoint_id"`
+	Limit        int    `q:"limit"`
+	Offset       int    `q:"offset"`
+}
+
+// BackupList is a page of the CBR backups
+type BackupList struct {
+	Backups []backups.BackupResp `json:"backups"`
+	Count   int                  `json:"count"`
+}
+
+func GetBackup(c *config.CloudCredentials, id string) (*backups.BackupResp, error) {
+	client, err := getCbrV3Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	backup, err := backups.Get(client, id)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "Error, backup %s does not exist", id)
+		}
+		return nil, status.Errorf(codes.Internal, "Error querying backup details: %s", err)
+	}
+	return backup, nil
+}
+
+func ListBackups(c *config.CloudCredentials, opts ListBackupsOpts) (*BackupList, error) {
+	client, err := getCbrV3Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	opts.ResourceType = volumeResourceType
+	q, err := golangsdk.BuildQueryString(opts)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Error building the query of backups: %s", err)
+	}
+	var rst BackupList
+	if _, err = client.Get(client.ServiceURL("backups")+q.String(), &rst, nil); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query backup list page: %v", err)
+	}
+	log.V(4).Infof("[DEBUG] query backup list page detail: %v", rst)
+	return &rst, nil
+}
+
+// CreateBackup creates a CBR backup of the volume in the vault, and returns the ID of the backup.
+// The volume is associated with the vault if it's not yet.
+func CreateBackup(c *config.CloudCredentials, vaultID, name, volumeID string) (string, error) {
+	client, err := getCbrV3Client(c)
+	if err != nil {
+		return "", err
+	}
+
+	if err = associateVaultResource(client, vaultID, volumeID); err != nil {
+		return "", err
+	}
+
+	body := map[string]interface{}{
+		"checkpoint": map[string]interface{}{
+			"vault_id": vaultID,
+			"parameters": map[string]interface{}{
+				"name":         name,
+				"resources":    []string{volumeID},
+				"auto_trigger": false,
+			},
+		},
+	}
+	log.V(4).Infof("[DEBUG] Create backup %s of volume %s in vault %s", name, volumeID, vaultID)
+
+	var rst struct {
+		Checkpoint struct {
+			ID string `json:"id"`
+		} `json:"checkpoint"`
+	}
+	if _, err = client.Post(client.ServiceURL("checkpoints"), body, &rst, nil); err != nil {
+		return "", modifyError(err, "Error creating backup %s of volume %s: %s", name, volumeID, err)
+	}
+
+	return waitBackupCreated(c, rst.Checkpoint.ID)
+}
+
+// waitBackupCreated waits for the backup of the checkpoint to be created,
+// it does not wait for the backup to be available.
+func waitBackupCreated(c *config.CloudCredentials, checkpointID string) (string, error) {
+	var backupID string
+	err := common.WaitForCompleted(func() (bool, error) {
+		list, err := ListBackups(c, ListBackupsOpts{CheckpointID: checkpointID})
+		if err != nil {
+			return false, err
+		}
+		if len(list.Backups) == 0 {
+			return false, nil
+		}
+		backupID = list.Backups[0].ID
+		return true, nil
+	})
+	return backupID, err
+}
+
+func associateVaultResource(client *golangsdk.ServiceClient, vaultID, volumeID string) error {
+	vault, err := vaults.Get(client, vaultID).Extract()
+	if err != nil {
+		if common.IsNotFound(err) {
+			return status.Errorf(codes.InvalidArgument, "Error, vault %s does not exist", vaultID)
+		}
+		return status.Errorf(codes.Internal, "Error querying vault details: %s", err)
+	}
+	for _, resource := range vault.Resources {
+		if resource.ID == volumeID {
+			return nil
+		}
+	}
+
+	opts := vaults.AssociateResourcesOpts{
+		Resources: []vaults.ResourceCreate{
+			{
+				ID:   volumeID,
+				Type: volumeResourceType,
+			},
+		},
+	}
+	if err = vaults.AssociateResources(client, vaultID, opts).Err; err != nil {
+		return status.Errorf(codes.Internal, "Error associating volume %s with vault %s: %s",
+			volumeID, vaultID, err)
+	}
+	return nil
+}
+
+// ReplicateBackup replicates the backup in the source region to the vault of the current region,
+// the replica is named by the name.
+func ReplicateBackup(c *config.CloudCredentials, sourceRegion, sourceProjectID, backupID, vaultID,
+	name string) error {
+	client, err := c.CbrV3RegionClient(sourceRegion, sourceProjectID)
+	if err != nil {
+		return status.Error(codes.Internal, fmt.Sprintf("Failed create CBR V3 client of region %s: %s",
+			sourceRegion, err))
+	}
+
+	body := map[string]interface{}{
+		"replicate": map[string]interface{}{
+			"destination_project_id": c.CloudClient.ProjectID,
+			"destination_region":     c.Global.Region,
+			"destination_vault_id":   vaultID,
+			"name":                   name,
+		},
+	}
+	log.V(4).Infof("[DEBUG] Replicate backup %s of region %s to vault %s", backupID, sourceRegion, vaultID)
+	_, err = client.Post(client.ServiceURL("backups", backupID, "replicate"), body, nil, &golangsdk.RequestOpts{
+		OkCodes: []int{200},
+	})
+	if err != nil {
+		return modifyError(err, "Error replicating backup %s of region %s: %s", backupID, sourceRegion, err)
+	}
+	return nil
+}
+
+func DeleteBackup(c *config.CloudCredentials, id string) error {
+	client, err := getCbrV3Client(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Delete(client.ServiceURL("backups", id), nil)
+	return err
+}
+
+func getCbrV3Client(c *config.CloudCredentials) (*golangsdk.ServiceClient, error) {
+	client, err := c.CbrV3Client()
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("Failed create CBR V3 client: %s", err))
+	}
+	return client, nil
+}
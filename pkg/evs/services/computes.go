@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/chnsz/golangsdk"
+	"github.com/chnsz/golangsdk/openstack/compute/v2/flavors"
 	serversv2 "github.com/chnsz/golangsdk/openstack/compute/v2/servers"
 	"github.com/chnsz/golangsdk/openstack/ecs/v1/block_devices"
 	"github.com/chnsz/golangsdk/openstack/ecs/v1/jobs"
@@ -32,6 +33,22 @@ func GetServer(c *config.CloudCredentials, serverID string) (*serversv2.Server,
 	return cs, nil
 }
 
+// GetFlavorExtraSpecs returns the extra specs of the ECS flavor,
+// e.g. the virtualization type and the generation of the flavor.
+func GetFlavorExtraSpecs(c *config.CloudCredentials, flavorID string) (map[string]string, error) {
+	client, err := getEcsV21Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	extraSpecs, err := flavors.ListExtraSpecs(client, flavorID).Extract()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Error querying the extra specs of ECS flavor %s: %s",
+			flavorID, err)
+	}
+	return extraSpecs, nil
+}
+
 func AttachVolumeCompleted(c *config.CloudCredentials, serverID, volumeID string) error {
 	client, err := getEcsV1Client(c)
 	if err != nil {
@@ -84,12 +101,23 @@ func waitForVolumeAttachingCondition(c *config.CloudCredentials, volumeID string
 }
 
 func DetachVolumeCompleted(c *config.CloudCredentials, serverID, volumeID string) error {
+	return detachVolumeCompleted(c, serverID, volumeID, 0)
+}
+
+// ForceDetachVolumeCompleted forcibly detaches the volume from the ECS instance which is not running,
+// the detach job may hang if the instance is shut off abnormally.
+func ForceDetachVolumeCompleted(c *config.CloudCredentials, serverID, volumeID string) error {
+	return detachVolumeCompleted(c, serverID, volumeID, 1)
+}
+
+func detachVolumeCompleted(c *config.CloudCredentials, serverID, volumeID string, deleteFlag int) error {
 	client, err := getEcsV1Client(c)
 	if err != nil {
 		return err
 	}
 	opts := block_devices.DetachOpts{
-		ServerId: serverID,
+		ServerId:   serverID,
+		DeleteFlag: deleteFlag,
 	}
 	log.V(4).Infof("[DEBUG] The option of detaching volume is %#v", opts)
 
@@ -108,6 +136,23 @@ func DetachVolumeCompleted(c *config.CloudCredentials, serverID, volumeID string
 	return nil
 }
 
+// WaitForVolumeDetached waits until the volume is no longer attached to the ECS instance,
+// the volume which is not shareable must be available.
+func WaitForVolumeDetached(c *config.CloudCredentials, serverID, volumeID string) error {
+	return common.WaitForCompleted(func() (bool, error) {
+		volume, err := GetVolume(c, volumeID)
+		if err != nil {
+			return false, err
+		}
+		for _, attachment := range volume.Attachments {
+			if attachment.ServerID == serverID {
+				return false, nil
+			}
+		}
+		return volume.Multiattach || volume.Status == EvsAvailableStatus, nil
+	})
+}
+
 func waitForVolumeAttached(c *config.CloudCredentials, jobID string) error {
 	condition, err := waitForCondition("attaching", c, jobID)
 	if err != nil {
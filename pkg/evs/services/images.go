@@ -0,0 +1,52 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/chnsz/golangsdk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+const ImageActiveStatus = "active"
+
+// Image is an IMS image which the volumes can be created from
+type Image struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	MinDisk    int    `json:"min_disk"`
+	DiskFormat string `json:"disk_format"`
+}
+
+func GetImage(c *config.CloudCredentials, id string) (*Image, error) {
+	client, err := getImsV2Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var rst struct {
+		Images []Image `json:"images"`
+	}
+	query := url.Values{"id": []string{id}}
+	if _, err = client.Get(client.ServiceURL("cloudimages")+"?"+query.Encode(), &rst, nil); err != nil {
+		return nil, status.Errorf(codes.Internal, "Error querying image details: %s", err)
+	}
+	log.V(4).Infof("[DEBUG] query image %s detail: %v", id, rst)
+	if len(rst.Images) == 0 {
+		return nil, status.Errorf(codes.NotFound, "Error, image %s does not exist", id)
+	}
+	return &rst.Images[0], nil
+}
+
+func getImsV2Client(c *config.CloudCredentials) (*golangsdk.ServiceClient, error) {
+	client, err := c.ImsV2Client()
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("Failed create IMS V2 client: %s", err))
+	}
+	return client, nil
+}
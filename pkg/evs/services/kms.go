@@ -0,0 +1,76 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/chnsz/golangsdk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+// DataKeyLength is the length in bits of the data keys generated by KMS
+const DataKeyLength = 256
+
+// DataKey is a data key generated by KMS, the plain text is hex encoded,
+// and the cipher text is the data key wrapped by the KMS key.
+type DataKey struct {
+	KeyID      string `json:"key_id"`
+	PlainText  string `json:"plain_text"`
+	CipherText string `json:"cipher_text"`
+}
+
+// CreateDataKey generates a data key which is wrapped by the KMS key
+func CreateDataKey(c *config.CloudCredentials, kmsID string) (*DataKey, error) {
+	client, err := getKmsV1Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	body := map[string]interface{}{
+		"key_id":         kmsID,
+		"datakey_length": strconv.Itoa(DataKeyLength),
+	}
+	var rst DataKey
+	_, err = client.Post(client.ServiceURL("create-datakey"), body, &rst, &golangsdk.RequestOpts{
+		OkCodes: []int{200},
+	})
+	if err != nil {
+		return nil, modifyError(err, "Error creating the data key of KMS key %s: %s", kmsID, err)
+	}
+	return &rst, nil
+}
+
+// DecryptDataKey unwraps the cipher text of the data key, and returns the hex encoded plain text
+func DecryptDataKey(c *config.CloudCredentials, kmsID, cipherText string) (string, error) {
+	client, err := getKmsV1Client(c)
+	if err != nil {
+		return "", err
+	}
+
+	body := map[string]interface{}{
+		"key_id":                kmsID,
+		"cipher_text":           cipherText,
+		"datakey_cipher_length": strconv.Itoa(DataKeyLength / 8),
+	}
+	var rst struct {
+		DataKey string `json:"data_key"`
+	}
+	_, err = client.Post(client.ServiceURL("decrypt-datakey"), body, &rst, &golangsdk.RequestOpts{
+		OkCodes: []int{200},
+	})
+	if err != nil {
+		return "", modifyError(err, "Error decrypting the data key with KMS key %s: %s", kmsID, err)
+	}
+	return rst.DataKey, nil
+}
+
+func getKmsV1Client(c *config.CloudCredentials) (*golangsdk.ServiceClient, error) {
+	client, err := c.KmsV1Client()
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("Failed create KMS V1 client: %s", err))
+	}
+	return client, nil
+}
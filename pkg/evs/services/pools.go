@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/chnsz/golangsdk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+// StoragePool is the dedicated distributed storage pool, the unit of the capacity is GB
+type StoragePool struct {
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	Status           string  `json:"status"`
+	AvailabilityZone string  `json:"availability_zone"`
+	StorageType      string  `json:"storage_type"`
+	Capacity         float64 `json:"capacity"`
+	UsedCapacity     float64 `json:"used_capacity"`
+}
+
+// Available returns the free capacity of the storage pool
+func (p *StoragePool) Available() int {
+	available := int(p.Capacity - p.UsedCapacity)
+	if available < 0 {
+		return 0
+	}
+	return available
+}
+
+func GetStoragePool(c *config.CloudCredentials, id string) (*StoragePool, error) {
+	client, err := getDssV1Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		Pool StoragePool `json:"pool"`
+	}
+	if _, err = client.Get(client.ServiceURL("pools", id), &body, nil); err != nil {
+		if common.IsNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "Error, dedicated storage pool %s does not exist", id)
+		}
+		return nil, status.Errorf(codes.Internal, "Error querying dedicated storage pool details: %s", err)
+	}
+	return &body.Pool, nil
+}
+
+func getDssV1Client(c *config.CloudCredentials) (*golangsdk.ServiceClient, error) {
+	client, err := c.DssV1Client()
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("Failed create DSS V1 client: %s", err))
+	}
+	return client, nil
+}
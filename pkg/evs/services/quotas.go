@@ -0,0 +1,94 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+const (
+	// UnlimitedQuota means that the quota is not limited
+	UnlimitedQuota = -1
+
+	quotaGigabytesKey = "gigabytes"
+)
+
+// QuotaUsage is the usage of an EVS quota item, the unit of the gigabytes items is GB
+type QuotaUsage struct {
+	InUse    int `json:"in_use"`
+	Limit    int `json:"limit"`
+	Reserved int `json:"reserved"`
+}
+
+// Available returns the remaining quota, or UnlimitedQuota if the quota is not limited
+func (q *QuotaUsage) Available() int {
+	if q.Limit < 0 {
+		return UnlimitedQuota
+	}
+	available := q.Limit - q.InUse - q.Reserved
+	if available < 0 {
+		return 0
+	}
+	return available
+}
+
+// GetAvailableGigabytes returns the remaining gigabytes quota of the project,
+// if volumeType is specified, the quota of the volume type is also taken into account.
+func GetAvailableGigabytes(c *config.CloudCredentials, volumeType string) (int, error) {
+	quotaSet, err := getQuotaUsageSet(c)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := []string{quotaGigabytesKey}
+	if volumeType != "" {
+		keys = append(keys, fmt.Sprintf("%s_%s", quotaGigabytesKey, volumeType))
+	}
+
+	available := UnlimitedQuota
+	for _, key := range keys {
+		raw, ok := quotaSet[key]
+		if !ok {
+			log.V(4).Infof("[DEBUG] The quota item %s is not found, skip it", key)
+			continue
+		}
+		var usage QuotaUsage
+		if err = json.Unmarshal(raw, &usage); err != nil {
+			return 0, status.Errorf(codes.Internal, "Error parsing the quota item %s: %s", key, err)
+		}
+		available = minQuota(available, usage.Available())
+	}
+	return available, nil
+}
+
+func getQuotaUsageSet(c *config.CloudCredentials) (map[string]json.RawMessage, error) {
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var body struct {
+		QuotaSet map[string]json.RawMessage `json:"quota_set"`
+	}
+	url := client.ServiceURL("os-quota-sets", client.ProjectID) + "?usage=True"
+	if _, err = client.Get(url, &body, nil); err != nil {
+		return nil, status.Errorf(codes.Internal, "Error querying EVS quotas: %s", err)
+	}
+	log.V(4).Infof("[DEBUG] query EVS quotas detail: %s", body.QuotaSet)
+	return body.QuotaSet, nil
+}
+
+func minQuota(a, b int) int {
+	if a == UnlimitedQuota {
+		return b
+	}
+	if b == UnlimitedQuota || a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"time"
+
+	"github.com/chnsz/golangsdk"
+	"github.com/chnsz/golangsdk/openstack/evs/v2/snapshots"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+const (
+	SnapshotGroupAvailableStatus = "available"
+	SnapshotGroupErrorStatus     = "error"
+)
+
+// SnapshotGroup is the snapshot consistency group, the snapshots of all the volumes in a group
+// are taken at the same point in time.
+type SnapshotGroup struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	ServerID  string    `json:"server_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateSnapshotGroupOpts contains the options of creating a snapshot consistency group
+type CreateSnapshotGroupOpts struct {
+	Name          string   `json:"name"`
+	VolumeIDs     []string `json:"volume_ids"`
+	InstantAccess bool     `json:"instant_access"`
+	Description   string   `json:"description,omitempty"`
+}
+
+// groupSnapshotListOpts filters the snapshots by the snapshot consistency group
+type groupSnapshotListOpts struct {
+	SnapshotGroupID string `q:"snapshot_group_id"`
+}
+
+func (opts groupSnapshotListOpts) ToSnapshotListQuery() (string, error) {
+	q, err := golangsdk.BuildQueryString(opts)
+	return q.String(), err
+}
+
+func CreateSnapshotGroup(c *config.CloudCredentials, opts CreateSnapshotGroupOpts) (string, error) {
+	client, err := getEvsV5Client(c)
+	if err != nil {
+		return "", err
+	}
+
+	body := map[string]interface{}{"snapshot_group": opts}
+	log.V(4).Infof("[DEBUG] Create snapshot group, and the options is %#v", opts)
+
+	var rst struct {
+		SnapshotGroupID string `json:"snapshot_group_id"`
+	}
+	if _, err = client.Post(client.ServiceURL("snapshot-groups"), body, &rst, nil); err != nil {
+		return "", modifyError(err, "Error creating snapshot group %s: %s", opts.Name, err)
+	}
+	return rst.SnapshotGroupID, nil
+}
+
+func GetSnapshotGroup(c *config.CloudCredentials, id string) (*SnapshotGroup, error) {
+	client, err := getEvsV5Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var rst struct {
+		SnapshotGroup SnapshotGroup `json:"snapshot_group"`
+	}
+	if _, err = client.Get(client.ServiceURL("snapshot-groups", id), &rst, nil); err != nil {
+		if common.IsNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "Error, snapshot group %s does not exist", id)
+		}
+		return nil, status.Errorf(codes.Internal, "Error querying snapshot group details: %s", err)
+	}
+	return &rst.SnapshotGroup, nil
+}
+
+func ListSnapshotGroupsByName(c *config.CloudCredentials, name string) ([]SnapshotGroup, error) {
+	client, err := getEvsV5Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err := golangsdk.BuildQueryString(struct {
+		Name string `q:"name"`
+	}{Name: name})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Error building the query of snapshot groups: %s", err)
+	}
+	var rst struct {
+		SnapshotGroups []SnapshotGroup `json:"snapshot_groups"`
+	}
+	if _, err = client.Get(client.ServiceURL("snapshot-groups")+q.String(), &rst, nil); err != nil {
+		return nil, status.Errorf(codes.Internal, "Error querying snapshot group list: %s", err)
+	}
+	return rst.SnapshotGroups, nil
+}
+
+// ListGroupSnapshots returns the snapshots that belong to the snapshot consistency group
+func ListGroupSnapshots(c *config.CloudCredentials, groupID string) ([]snapshots.Snapshot, error) {
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := snapshots.ListPage(client, groupSnapshotListOpts{SnapshotGroupID: groupID})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query the snapshots of snapshot group %s: %v",
+			groupID, err)
+	}
+	log.V(4).Infof("[DEBUG] query the snapshots of snapshot group %s detail: %v", groupID, page)
+	return page.Snapshots, nil
+}
+
+func DeleteSnapshotGroup(c *config.CloudCredentials, id string) error {
+	client, err := getEvsV5Client(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Delete(client.ServiceURL("snapshot-groups", id), nil)
+	return err
+}
+
+// WaitGroupSnapshotsCreated waits for the snapshots of all the volumes in the group to be created,
+// it does not wait for the snapshots to be available.
+func WaitGroupSnapshotsCreated(c *config.CloudCredentials, groupID string, count int) ([]snapshots.Snapshot,
+	error) {
+	var groupSnapshots []snapshots.Snapshot
+	err := common.WaitForCompleted(func() (bool, error) {
+		group, err := GetSnapshotGroup(c, groupID)
+		if err != nil {
+			return false, err
+		}
+		if group.Status == SnapshotGroupErrorStatus {
+			return false, status.Errorf(codes.Internal, "Error creating snapshot group %s, status: %s",
+				groupID, group.Status)
+		}
+
+		groupSnapshots, err = ListGroupSnapshots(c, groupID)
+		if err != nil {
+			return false, err
+		}
+		return len(groupSnapshots) >= count, nil
+	})
+	return groupSnapshots, err
+}
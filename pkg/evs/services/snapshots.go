@@ -2,6 +2,7 @@ package services
 
 import (
 	"github.com/chnsz/golangsdk/openstack/evs/v2/snapshots"
+	"github.com/chnsz/golangsdk/openstack/evs/v2/tags"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	log "k8s.io/klog/v2"
@@ -10,6 +11,12 @@ import (
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
 )
 
+const (
+	SnapshotCreatingStatus  = "creating"
+	SnapshotAvailableStatus = "available"
+	SnapshotErrorStatus     = "error"
+)
+
 func GetSnapshot(c *config.CloudCredentials, id string) (*snapshots.Snapshot, error) {
 	client, err := getEvsV2Client(c)
 	if err != nil {
@@ -61,9 +68,24 @@ func CreateSnapshot(c *config.CloudCredentials, opts *snapshots.CreateOpts) (*sn
 	return snapshots.Create(client, opts).Extract()
 }
 
+// CreateSnapshotTags sets the tags of the snapshot, the existing tags will be replaced.
+func CreateSnapshotTags(c *config.CloudCredentials, snapshotID string, snapTags map[string]string) error {
+	if len(snapTags) == 0 {
+		return nil
+	}
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return err
+	}
+
+	opts := tags.CreateOpts{Tags: snapTags}
+	if _, err = tags.Create(client, "snapshots", snapshotID, opts).Extract(); err != nil {
+		return status.Errorf(codes.Internal, "Failed to set tags of snapshot %s: %v", snapshotID, err)
+	}
+	return nil
+}
+
 func WaitSnapshotReady(c *config.CloudCredentials, snapshotID string) error {
-	availableStatus := "available"
-	creatingStatus := "creating"
 	err := common.WaitForCompleted(func() (done bool, err error) {
 		snapshot, err := GetSnapshot(c, snapshotID)
 		if err != nil {
@@ -71,10 +93,10 @@ func WaitSnapshotReady(c *config.CloudCredentials, snapshotID string) error {
 				"Failed to query snapshot when wait snapshot ready: %v", err)
 		}
 		log.V(4).Infof("[DEBUG] query snapshot detail when wait snapshot ready detail: %v", snapshot)
-		if snapshot.Status == availableStatus {
+		if snapshot.Status == SnapshotAvailableStatus {
 			return true, nil
 		}
-		if snapshot.Status == creatingStatus {
+		if snapshot.Status == SnapshotCreatingStatus {
 			return false, nil
 		}
 		return false, status.Error(codes.Internal, "created snapshot status is not available")
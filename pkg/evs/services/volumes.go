@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/chnsz/golangsdk"
@@ -36,6 +37,21 @@ func CreateVolumeCompleted(c *config.CloudCredentials, otps *cloudvolumes.Create
 	return waitForJobFinished(c, "creation", job.JobID)
 }
 
+// CreateVolume submits the creation of the volume without waiting for it to be available
+func CreateVolume(c *config.CloudCredentials, opts *cloudvolumes.CreateOpts) error {
+	client, err := getEvsV21Client(c)
+	if err != nil {
+		return err
+	}
+
+	job, err := cloudvolumes.Create(client, *opts).Extract()
+	if err != nil {
+		return modifyError(err, "Error creating EVS volume, error: %s, createOpts: %#v", err, opts)
+	}
+	log.V(4).Infof("[DEBUG] The volume creation is submitted successfully, job ID: %s", job.JobID)
+	return nil
+}
+
 func CreateCinderCompleted(c *config.CloudCredentials, opts *cloudvolumes.CreateOpts) (string, error) {
 	client, err := getEvsV2Client(c)
 	if err != nil {
@@ -48,9 +64,11 @@ func CreateCinderCompleted(c *config.CloudCredentials, opts *cloudvolumes.Create
 		VolumeType:       opts.Volume.VolumeType,
 		AvailabilityZone: opts.Volume.AvailabilityZone,
 		SnapshotID:       opts.Volume.SnapshotID,
+		ImageID:          opts.Volume.ImageID,
 		Metadata:         opts.Volume.Metadata,
 		IOPS:             opts.Volume.IOPS,
 		Throughput:       opts.Volume.Throughput,
+		Multiattach:      opts.Volume.Multiattach,
 	}
 
 	cinderVol, err := cinder.Create(client, createOpts).Extract()
@@ -108,6 +126,15 @@ func CheckVolumeExists(credentials *config.CloudCredentials, name string, sizeGB
 			fmt.Sprintf("Failed to query the volume by name, cannot verify whether it exists: %s", err))
 	}
 
+	// The volumes are filtered by the exact name, the other volumes containing the name are excluded
+	matched := make([]cloudvolumes.Volume, 0, len(volumes))
+	for _, vol := range volumes {
+		if vol.Name == name {
+			matched = append(matched, vol)
+		}
+	}
+	volumes = matched
+
 	if len(volumes) == 1 {
 		vol := volumes[0]
 		if sizeGB != vol.Size {
@@ -147,6 +174,93 @@ func ExpandVolume(c *config.CloudCredentials, id string, newSize int) error {
 	return err
 }
 
+// RetypeOpts contains the options of changing the type of a volume,
+// IOPS and Throughput are only required when the new type is GPSSD2 or ESSD2.
+type RetypeOpts struct {
+	NewType    string `json:"new_type"`
+	IOPS       int    `json:"iops,omitempty"`
+	Throughput int    `json:"throughput,omitempty"`
+}
+
+// QoSOpts contains the options of changing the provisioned IOPS and throughput of a volume
+type QoSOpts struct {
+	IOPS       int `json:"iops,omitempty"`
+	Throughput int `json:"throughput,omitempty"`
+}
+
+type jobResponse struct {
+	JobID string `json:"job_id"`
+}
+
+func RetypeVolume(c *config.CloudCredentials, id string, opts RetypeOpts) error {
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"os-retype": opts}
+	log.V(4).Infof("[DEBUG] Retype volume %s, and the options is %#v", id, opts)
+
+	var job jobResponse
+	if _, err = client.Post(client.ServiceURL("volumes", id, "retype"), body, &job, nil); err != nil {
+		return modifyError(err, "Error changing the type of volume %s to %s: %s", id, opts.NewType, err)
+	}
+	log.V(4).Infof("[DEBUG] The volume retyping is submitted successfully and the job is running.")
+
+	_, err = waitForJobFinished(c, "retyping", job.JobID)
+	return err
+}
+
+func ModifyVolumeQoS(c *config.CloudCredentials, id string, opts QoSOpts) error {
+	client, err := getEvsV5Client(c)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"qos_modify": opts}
+	log.V(4).Infof("[DEBUG] Modify the QoS of volume %s, and the options is %#v", id, opts)
+
+	var job jobResponse
+	if _, err = client.Put(client.ServiceURL("cloudvolumes", id, "qos"), body, &job, nil); err != nil {
+		return modifyError(err, "Error modifying the IOPS and throughput of volume %s: %s", id, err)
+	}
+	log.V(4).Infof("[DEBUG] The volume QoS modifying is submitted successfully and the job is running.")
+
+	_, err = waitForJobFinished(c, "QoS modifying", job.JobID)
+	return err
+}
+
+// modifyError converts the errors of EVS API to the gRPC errors
+func modifyError(err error, format string, a ...interface{}) error {
+	switch {
+	case errors.As(err, &golangsdk.ErrDefault400{}):
+		return status.Errorf(codes.InvalidArgument, format, a...)
+	case common.IsNotFound(err):
+		return status.Errorf(codes.NotFound, format, a...)
+	case errors.As(err, &golangsdk.ErrDefault403{}):
+		return status.Errorf(codes.PermissionDenied, format, a...)
+	default:
+		return status.Errorf(codes.Internal, format, a...)
+	}
+}
+
+// GetVolumeMetadata returns all the metadata of the volume, including the custom keys
+func GetVolumeMetadata(c *config.CloudCredentials, id string) (map[string]string, error) {
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return nil, err
+	}
+
+	volume, err := cinder.Get(client, id).Extract()
+	if err != nil {
+		if common.IsNotFound(err) {
+			return nil, status.Errorf(codes.NotFound, "Error, volume %s does not exist", id)
+		}
+		return nil, status.Errorf(codes.Internal, "Error querying volume metadata: %s", err)
+	}
+	return volume.Metadata, nil
+}
+
 func DeleteVolume(c *config.CloudCredentials, id string) error {
 	client, err := getEvsV2Client(c)
 	if err != nil {
@@ -155,6 +269,58 @@ func DeleteVolume(c *config.CloudCredentials, id string) error {
 	return cloudvolumes.Delete(client, id, nil).Err
 }
 
+// RenameVolume updates the name of the volume
+func RenameVolume(c *config.CloudCredentials, id, name string) error {
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return err
+	}
+
+	if err = cloudvolumes.Update(client, id, cloudvolumes.UpdateOpts{Name: name}).Err; err != nil {
+		return modifyError(err, "Error renaming volume %s to %s: %s", id, name, err)
+	}
+	return nil
+}
+
+// DeleteVolumeMetadata deletes the metadata key of the volume, it's ignored if the key does not exist
+func DeleteVolumeMetadata(c *config.CloudCredentials, id, key string) error {
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Delete(client.ServiceURL("volumes", id, "metadata", key), &golangsdk.RequestOpts{
+		OkCodes: []int{200},
+	})
+	if err != nil && !common.IsNotFound(err) {
+		return modifyError(err, "Error deleting the metadata %s of volume %s: %s", key, id, err)
+	}
+	return nil
+}
+
+// ForceDetachVolume detaches the volume attachment through the EVS API,
+// which is used when the ECS instance no longer exists and can not detach the volume.
+func ForceDetachVolume(c *config.CloudCredentials, volumeID, attachmentID string) error {
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"os-force_detach": map[string]interface{}{
+			"attachment_id": attachmentID,
+		},
+	}
+	_, err = client.Post(client.ServiceURL("volumes", volumeID, "action"), body, nil, &golangsdk.RequestOpts{
+		OkCodes: []int{202},
+	})
+	if err != nil {
+		return modifyError(err, "Error forcibly detaching the attachment %s of volume %s: %s",
+			attachmentID, volumeID, err)
+	}
+	return nil
+}
+
 func ListVolumes(c *config.CloudCredentials, opts cloudvolumes.ListOpts) ([]cloudvolumes.Volume, error) {
 	client, err := getEvsV2Client(c)
 	if err != nil {
@@ -217,6 +383,15 @@ func getEvsV21Client(c *config.CloudCredentials) (*golangsdk.ServiceClient, erro
 	return client, nil
 }
 
+func getEvsV5Client(c *config.CloudCredentials) (*golangsdk.ServiceClient, error) {
+	client, err := c.EvsV5Client()
+	if err != nil {
+		logMsg := fmt.Sprintf("Failed create EVS V5 client: %s", err)
+		return nil, status.Error(codes.Internal, logMsg)
+	}
+	return client, nil
+}
+
 func getJobV1Client(c *config.CloudCredentials) (*golangsdk.ServiceClient, error) {
 	client, err := c.EvsV1Client()
 	if err != nil {
@@ -225,3 +400,20 @@ func getJobV1Client(c *config.CloudCredentials) (*golangsdk.ServiceClient, error
 	}
 	return client, nil
 }
+
+// UpdateVolumeMetadata adds the metadata to the volume, the existing keys not specified are kept
+func UpdateVolumeMetadata(c *config.CloudCredentials, id string, metadata map[string]string) error {
+	client, err := getEvsV2Client(c)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"metadata": metadata}
+	_, err = client.Post(client.ServiceURL("volumes", id, "metadata"), body, nil, &golangsdk.RequestOpts{
+		OkCodes: []int{200},
+	})
+	if err != nil {
+		return modifyError(err, "Error updating the metadata of volume %s: %s", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package evs
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/chnsz/golangsdk/openstack/evs/v2/cloudvolumes"
+	"github.com/chnsz/golangsdk/openstack/evs/v2/snapshots"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/common"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/evs/services"
+)
+
+const (
+	// CrossZoneRestoreKey in StorageClass parameters, the EVS snapshots are copied to the AZ of the volume
+	// through a CBR backup when the AZ is different from the snapshot
+	CrossZoneRestoreKey = "crossZoneRestore"
+	// SourceRegionKey in StorageClass parameters, the backups are replicated from the region before restored
+	SourceRegionKey = "sourceRegion"
+	// SourceProjectIDKey in StorageClass parameters, the project of the source region
+	SourceProjectIDKey = "sourceProjectId"
+
+	// copyResourcePrefix is the prefix of the temporary volumes and backups used to copy the snapshots
+	copyResourcePrefix = "copy"
+)
+
+// restoreCopyOpts is the options to copy the snapshot to the AZ or region of the volume before restored
+type restoreCopyOpts struct {
+	crossZone       bool
+	sourceRegion    string
+	sourceProjectID string
+	vaultID         string
+}
+
+func parseRestoreCopyOpts(cc *config.CloudCredentials, parameters map[string]string) (*restoreCopyOpts, error) {
+	opts := &restoreCopyOpts{
+		sourceRegion:    parameters[SourceRegionKey],
+		sourceProjectID: parameters[SourceProjectIDKey],
+		vaultID:         parameters["vaultId"],
+	}
+	if v, ok := parameters[CrossZoneRestoreKey]; ok {
+		crossZone, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "%s error, expected a boolean, but got %s",
+				CrossZoneRestoreKey, v)
+		}
+		opts.crossZone = crossZone
+	}
+	if opts.sourceRegion == cc.Global.Region {
+		opts.sourceRegion = ""
+	}
+	if opts.sourceRegion != "" && opts.sourceProjectID == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "%s is required when %s is specified",
+			SourceProjectIDKey, SourceRegionKey)
+	}
+	if (opts.crossZone || opts.sourceRegion != "") && opts.vaultID == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "vaultId is required when %s or %s is specified",
+			CrossZoneRestoreKey, SourceRegionKey)
+	}
+	return opts, nil
+}
+
+func getCopyName(volName string) string {
+	return fmt.Sprintf("%s-%s", copyResourcePrefix, volName)
+}
+
+// prepareSnapshotRestore returns the snapshot ID and the AZ to restore the volume from, the snapshot is copied
+// to the AZ or region of the volume when required. The copy is not waited, codes.Unavailable is returned while
+// it is in progress, and the external-provisioner retries the CreateVolume call.
+func prepareSnapshotRestore(cc *config.CloudCredentials, volName, snapshotID, volumeAz string, specified bool,
+	requirement *csi.TopologyRequirement, opts *restoreCopyOpts) (string, string, error) {
+	if backupID, ok := parseBackupSnapshotID(snapshotID); ok {
+		// The backups can be restored in any AZ of the region
+		if opts.sourceRegion == "" {
+			return snapshotID, volumeAz, checkBackupAvailable(cc, backupID)
+		}
+		replicaID, err := replicateSnapshotBackup(cc, volName, backupID, opts)
+		if err != nil {
+			return "", "", err
+		}
+		return backupSnapshotIDPrefix + replicaID, volumeAz, nil
+	}
+
+	if opts.sourceRegion != "" {
+		return "", "", status.Errorf(codes.InvalidArgument, "Error, only the snapshots backed by backups "+
+			"can be restored from the region %s, but got %s", opts.sourceRegion, snapshotID)
+	}
+	snap, err := services.GetSnapshot(cc, snapshotID)
+	if err != nil {
+		if common.IsNotFound(err) {
+			return "", "", status.Errorf(codes.NotFound, "Error, snapshot ID %s does not exist.", snapshotID)
+		}
+		return "", "", status.Errorf(codes.Internal, "Failed to retrieve the snapshot %s: %v", snapshotID, err)
+	}
+	if err = checkSnapshotFailed(snap); err != nil {
+		return "", "", err
+	}
+	if snap.Status != services.SnapshotAvailableStatus {
+		return "", "", status.Errorf(codes.Unavailable, "Snapshot %s is not ready to use, status: %s",
+			snapshotID, snap.Status)
+	}
+	sourceVol, err := services.GetVolume(cc, snap.VolumeID)
+	if err != nil {
+		return "", "", status.Errorf(codes.Internal, "Failed to retrieve the source volume %s of snapshot %s: %v",
+			snap.VolumeID, snapshotID, err)
+	}
+
+	// EVS can only restore a snapshot in the AZ where its source volume is located
+	sourceAz := sourceVol.AvailabilityZone
+	if volumeAz == "" || volumeAz == sourceAz {
+		return snapshotID, sourceAz, nil
+	}
+	if !specified && topologyContainsZone(requirement, sourceAz) {
+		log.Infof("The volume will be restored in the AZ %s of snapshot %s instead of %s",
+			sourceAz, snapshotID, volumeAz)
+		return snapshotID, sourceAz, nil
+	}
+	if !opts.crossZone {
+		return "", "", status.Errorf(codes.InvalidArgument, "Error, the volume restored from snapshot %s "+
+			"must be in the availability zone %s, but got %s, set %s to copy the snapshot to the availability zone",
+			snapshotID, sourceAz, volumeAz, CrossZoneRestoreKey)
+	}
+
+	backupID, err := copySnapshotToBackup(cc, volName, snap, sourceVol, opts.vaultID)
+	if err != nil {
+		return "", "", err
+	}
+	return backupSnapshotIDPrefix + backupID, volumeAz, nil
+}
+
+// copySnapshotToBackup copies the snapshot to a CBR backup, which can be restored in any AZ.
+// The snapshot is restored to a temporary volume in the AZ of the snapshot, then the volume is backed up.
+func copySnapshotToBackup(cc *config.CloudCredentials, volName string, snap *snapshots.Snapshot,
+	sourceVol *cloudvolumes.Volume, vaultID string) (string, error) {
+	name := getCopyName(volName)
+	backup, err := getCopyBackup(cc, name, vaultID)
+	if err != nil || backup != "" {
+		return backup, err
+	}
+
+	vol, err := getCopyVolume(cc, name)
+	if err != nil {
+		return "", err
+	}
+	if vol == nil {
+		createOpts := &cloudvolumes.CreateOpts{
+			Volume: cloudvolumes.VolumeOpts{
+				Name:             name,
+				Size:             snap.Size,
+				VolumeType:       sourceVol.VolumeType,
+				AvailabilityZone: sourceVol.AvailabilityZone,
+				SnapshotID:       snap.ID,
+				Metadata:         map[string]string{CreateForVolumeIDKey: "true"},
+			},
+		}
+		if err = services.CreateVolume(cc, createOpts); err != nil {
+			return "", err
+		}
+		return "", status.Errorf(codes.Unavailable, "Copying snapshot %s: creating the temporary volume %s "+
+			"in availability zone %s", snap.ID, name, sourceVol.AvailabilityZone)
+	}
+	// The failed temporary volume is deleted, and created again when the request is retried
+	if vol.Status == "error" {
+		if err = services.DeleteVolume(cc, vol.ID); err != nil {
+			return "", err
+		}
+		return "", status.Errorf(codes.Unavailable, "Copying snapshot %s: the temporary volume %s "+
+			"is in %s status, deleted it to retry", snap.ID, vol.ID, vol.Status)
+	}
+	if vol.Status != "available" {
+		return "", status.Errorf(codes.Unavailable, "Copying snapshot %s: the temporary volume %s is %s",
+			snap.ID, vol.ID, vol.Status)
+	}
+
+	backupID, err := services.CreateBackup(cc, vaultID, name, vol.ID)
+	if err != nil {
+		return "", err
+	}
+	return "", status.Errorf(codes.Unavailable, "Copying snapshot %s: creating the backup %s of "+
+		"the temporary volume %s", snap.ID, backupID, vol.ID)
+}
+
+// replicateSnapshotBackup replicates the backup of the source region to the vault, and returns the replica ID
+func replicateSnapshotBackup(cc *config.CloudCredentials, volName, backupID string, opts *restoreCopyOpts) (
+	string, error) {
+	name := getCopyName(volName)
+	replicaID, err := getCopyBackup(cc, name, opts.vaultID)
+	if err != nil || replicaID != "" {
+		return replicaID, err
+	}
+
+	if err = services.ReplicateBackup(cc, opts.sourceRegion, opts.sourceProjectID, backupID, opts.vaultID,
+		name); err != nil {
+		return "", err
+	}
+	return "", status.Errorf(codes.Unavailable, "Replicating backup %s of region %s to vault %s",
+		backupID, opts.sourceRegion, opts.vaultID)
+}
+
+// getCopyBackup returns the ID of the backup copied from the snapshot when it's available,
+// codes.Unavailable is returned if the backup is not ready.
+func getCopyBackup(cc *config.CloudCredentials, name, vaultID string) (string, error) {
+	list, err := services.ListBackups(cc, services.ListBackupsOpts{Name: name, VaultID: vaultID})
+	if err != nil {
+		return "", err
+	}
+	for i := range list.Backups {
+		backup := &list.Backups[i]
+		if backup.Name != name {
+			continue
+		}
+		// The failed copy is deleted, and created again when the request is retried
+		if backup.Status == services.BackupErrorStatus {
+			if err = services.DeleteBackup(cc, backup.ID); err != nil {
+				return "", err
+			}
+			return "", status.Errorf(codes.Unavailable, "Copying snapshot: the backup %s is in %s status, "+
+				"deleted it to retry", backup.ID, backup.Status)
+		}
+		if backup.Status != services.BackupAvailableStatus {
+			return "", status.Errorf(codes.Unavailable, "Copying snapshot: the backup %s is %s",
+				backup.ID, backup.Status)
+		}
+		return backup.ID, nil
+	}
+	return "", nil
+}
+
+func getCopyVolume(cc *config.CloudCredentials, name string) (*cloudvolumes.Volume, error) {
+	volumes, err := services.ListVolumes(cc, cloudvolumes.ListOpts{Name: name})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to query the temporary volume %s: %v", name, err)
+	}
+	for i := range volumes {
+		if volumes[i].Name == name {
+			return &volumes[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// cleanupRestoreCopy deletes the temporary volumes and backups used to copy the snapshot,
+// the failures are only logged and the deletion will be retried by the next CreateVolume call.
+func cleanupRestoreCopy(cc *config.CloudCredentials, volName string, opts *restoreCopyOpts) {
+	if !opts.crossZone && opts.sourceRegion == "" {
+		return
+	}
+
+	name := getCopyName(volName)
+	if vol, err := getCopyVolume(cc, name); err != nil {
+		log.Warningf("Failed to query the temporary volume %s: %v", name, err)
+	} else if vol != nil {
+		if err = services.DeleteVolume(cc, vol.ID); err != nil && !common.IsNotFound(err) {
+			log.Warningf("Failed to delete the temporary volume %s: %v", vol.ID, err)
+		} else {
+			log.Infof("Successfully deleted the temporary volume %s", vol.ID)
+		}
+	}
+
+	list, err := services.ListBackups(cc, services.ListBackupsOpts{Name: name, VaultID: opts.vaultID})
+	if err != nil {
+		log.Warningf("Failed to query the temporary backup %s: %v", name, err)
+		return
+	}
+	for _, backup := range list.Backups {
+		if backup.Name != name {
+			continue
+		}
+		if err = deleteBackupSnapshot(cc, backup.ID); err != nil {
+			log.Warningf("Failed to delete the temporary backup %s: %v", backup.ID, err)
+		}
+	}
+}
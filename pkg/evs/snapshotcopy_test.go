@@ -0,0 +1,105 @@
+package evs
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+func TestParseRestoreCopyOpts(t *testing.T) {
+	cc := &config.CloudCredentials{}
+	cc.Global.Region = "cn-north-4"
+
+	tests := []struct {
+		name        string
+		parameters  map[string]string
+		expected    *restoreCopyOpts
+		code        codes.Code
+		description string
+	}{
+		{
+			name:        "test1",
+			parameters:  map[string]string{},
+			expected:    &restoreCopyOpts{},
+			description: "no copy options",
+		},
+		{
+			name:        "test2",
+			parameters:  map[string]string{CrossZoneRestoreKey: "true", "vaultId": "vault-id"},
+			expected:    &restoreCopyOpts{crossZone: true, vaultID: "vault-id"},
+			description: "cross zone restore",
+		},
+		{
+			name:        "test3",
+			parameters:  map[string]string{CrossZoneRestoreKey: "false"},
+			expected:    &restoreCopyOpts{},
+			description: "cross zone restore is disabled, the vault is not required",
+		},
+		{
+			name:        "test4",
+			parameters:  map[string]string{CrossZoneRestoreKey: "yes", "vaultId": "vault-id"},
+			code:        codes.InvalidArgument,
+			description: "crossZoneRestore is not a boolean",
+		},
+		{
+			name:        "test5",
+			parameters:  map[string]string{CrossZoneRestoreKey: "true"},
+			code:        codes.InvalidArgument,
+			description: "the vault is required for cross zone restore",
+		},
+		{
+			name: "test6",
+			parameters: map[string]string{
+				SourceRegionKey:    "cn-east-3",
+				SourceProjectIDKey: "project-id",
+				"vaultId":          "vault-id",
+			},
+			expected: &restoreCopyOpts{
+				sourceRegion:    "cn-east-3",
+				sourceProjectID: "project-id",
+				vaultID:         "vault-id",
+			},
+			description: "cross region restore",
+		},
+		{
+			name:        "test7",
+			parameters:  map[string]string{SourceRegionKey: "cn-east-3", "vaultId": "vault-id"},
+			code:        codes.InvalidArgument,
+			description: "the source project is required for cross region restore",
+		},
+		{
+			name:        "test8",
+			parameters:  map[string]string{SourceRegionKey: "cn-east-3", SourceProjectIDKey: "project-id"},
+			code:        codes.InvalidArgument,
+			description: "the vault is required for cross region restore",
+		},
+		{
+			name:        "test9",
+			parameters:  map[string]string{SourceRegionKey: "cn-north-4"},
+			expected:    &restoreCopyOpts{},
+			description: "the source region is the region of the cluster, it's ignored",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts, err := parseRestoreCopyOpts(cc, testCase.parameters)
+			if testCase.code != codes.OK {
+				if status.Code(err) != testCase.code {
+					t.Fatalf("expected code: %v, got error: %v", testCase.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(opts, testCase.expected) {
+				t.Errorf("expected: %+v, got: %+v", testCase.expected, opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package evs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/chnsz/golangsdk/openstack/evs/v2/cloudvolumes"
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	log "k8s.io/klog/v2"
+	mountutils "k8s.io/mount-utils"
+)
+
+const procMountInfoPath = "/proc/self/mountinfo"
+
+// getVolumeCondition reports the volumes in error statuses as abnormal, e.g. error, error_extending,
+// error_restoring, they can not be used until they are recovered by the administrator.
+func getVolumeCondition(vol *cloudvolumes.Volume) *csi.VolumeCondition {
+	return &csi.VolumeCondition{
+		Abnormal: strings.HasPrefix(vol.Status, "error"),
+		Message:  fmt.Sprintf("Volume %s is in %s status", vol.ID, vol.Status),
+	}
+}
+
+// checkNodeVolumeCondition checks the volume on the node, the volume is abnormal when the device is missing,
+// e.g. it's detached behind our back, the file system has I/O errors or is remounted read-only.
+func checkNodeVolumeCondition(volumePath string) *csi.VolumeCondition {
+	info, err := os.Stat(volumePath)
+	if err != nil {
+		return abnormalCondition("Failed to stat the volume path %s: %v", volumePath, err)
+	}
+
+	if info.Mode()&os.ModeDevice != 0 {
+		// The device of the block volume is missing when it's detached
+		f, err := os.OpenFile(volumePath, os.O_RDONLY, 0)
+		if err != nil {
+			return abnormalCondition("The device of the block volume %s is not accessible: %v", volumePath, err)
+		}
+		defer f.Close()
+		return &csi.VolumeCondition{Abnormal: false, Message: "The device of the block volume is accessible"}
+	}
+
+	mountInfo, err := findMountInfo(volumePath)
+	if err != nil {
+		log.Warningf("Failed to find the mount info of %s: %v", volumePath, err)
+	} else if mountInfo != nil {
+		if strings.HasPrefix(mountInfo.Source, "/dev/") {
+			if _, err = os.Stat(mountInfo.Source); err != nil {
+				return abnormalCondition("The device %s of the volume path %s is missing: %v",
+					mountInfo.Source, volumePath, err)
+			}
+		}
+		// The super block is read-only when the file system is remounted read-only due to errors,
+		// a read-only bind mount only changes the mount options.
+		for _, opt := range mountInfo.SuperOptions {
+			if opt == "ro" {
+				return abnormalCondition("The file system of the volume path %s is remounted read-only", volumePath)
+			}
+		}
+	}
+
+	if err = readDir(volumePath); err != nil {
+		if errors.Is(err, syscall.EIO) {
+			return abnormalCondition("The file system of the volume path %s has I/O errors: %v", volumePath, err)
+		}
+		return abnormalCondition("Failed to read the volume path %s: %v", volumePath, err)
+	}
+	return &csi.VolumeCondition{Abnormal: false, Message: "The file system of the volume is healthy"}
+}
+
+func abnormalCondition(format string, args ...interface{}) *csi.VolumeCondition {
+	message := fmt.Sprintf(format, args...)
+	log.Warning(message)
+	return &csi.VolumeCondition{Abnormal: true, Message: message}
+}
+
+// findMountInfo returns the mount info of the mount point, nil if it's not a mount point
+func findMountInfo(mountPoint string) (*mountutils.MountInfo, error) {
+	mountPoint, err := filepath.EvalSymlinks(mountPoint)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := mountutils.ParseMountInfo(procMountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	// The last one is the top of the stacked mounts
+	for i := len(infos) - 1; i >= 0; i-- {
+		if infos[i].MountPoint == mountPoint {
+			return &infos[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// readDir reads the first entry of the directory to detect the I/O errors of the file system
+func readDir(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err = f.Readdirnames(1); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package obs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils"
+)
+
+const (
+	// ProtocolVersionLegacy authenticates the commands with the AES-CBC token under the build-time secret
+	ProtocolVersionLegacy = 1
+	// ProtocolVersionHMAC authenticates the commands with the HMAC-SHA256 signature of the canonical request,
+	// under the key generated by the connector on the host.
+	ProtocolVersionHMAC = 2
+
+	// ActionVersion returns the latest protocol version supported by the connector, it's not authenticated
+	ActionVersion string = "version"
+
+	// KeyFile is the HMAC key generated by the connector when it starts, which is readable by root only
+	KeyFile = "/var/lib/csi/connector.key"
+	// SignatureWindow is the maximum clock skew of the signed commands, the nonces are remembered within the window
+	SignatureWindow = 5 * time.Minute
+)
+
+// CanonicalRequest returns the string to sign of the command
+func CanonicalRequest(cmd *CommandRPC) string {
+	return strings.Join([]string{
+		strconv.Itoa(cmd.Version),
+		cmd.Action,
+		strconv.FormatInt(cmd.Timestamp, 10),
+		cmd.Nonce,
+		utils.Sha256(cmd.Parameters),
+	}, "\n")
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the canonical request
+func Sign(key []byte, cmd *CommandRPC) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(CanonicalRequest(cmd)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// negotiatedVersion is the protocol version of the connector once it supports ProtocolVersionHMAC, the plugin
+// never falls back to the legacy protocol afterwards, so that a forged or broken version action can not
+// downgrade the authentication.
+var (
+	negotiatedVersion int
+	negotiatedMutex   sync.Mutex
+)
+
+// getProtocolVersion returns the latest protocol version supported by the connector, the connectors without
+// the version action only support the legacy protocol, and they are asked again on the next command.
+func getProtocolVersion(mountClient http.Client) int {
+	negotiatedMutex.Lock()
+	defer negotiatedMutex.Unlock()
+	if negotiatedVersion >= ProtocolVersionHMAC {
+		return negotiatedVersion
+	}
+
+	data, err := queryCommand(CommandRPC{Action: ActionVersion}, mountClient)
+	if err != nil {
+		log.Warningf("Failed to get the protocol version of the connector, use the legacy protocol: %v", err)
+		return ProtocolVersionLegacy
+	}
+	version, err := strconv.Atoi(data)
+	if err != nil || version < ProtocolVersionHMAC {
+		return ProtocolVersionLegacy
+	}
+	log.Infof("The connector supports protocol version %d", version)
+	negotiatedVersion = version
+	return version
+}
+
+func newCommandRPC(mountClient http.Client, action string, parameters map[string]string) (*CommandRPC, error) {
+	if getProtocolVersion(mountClient) < ProtocolVersionHMAC {
+		return newLegacyCommandRPC(action, parameters)
+	}
+
+	key, err := os.ReadFile(KeyFile)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to read the connector key %s: %v", KeyFile, err)
+	}
+	cmd := &CommandRPC{
+		Version:    ProtocolVersionHMAC,
+		Action:     action,
+		Parameters: parameters,
+		Timestamp:  time.Now().Unix(),
+		Nonce:      uuid.New().String(),
+	}
+	cmd.Signature = Sign(key, cmd)
+	return cmd, nil
+}
+
+func newLegacyCommandRPC(action string, parameters map[string]string) (*CommandRPC, error) {
+	ciphertext := utils.Sha256(parameters)
+	token, err := utils.EncryptAESCBC(Secret, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandRPC{
+		Action:     action,
+		Token:      token,
+		Parameters: parameters,
+	}, nil
+}
+
+// Authenticator authenticates the commands received by the connector
+type Authenticator struct {
+	keyFile          string
+	allowLegacyToken bool
+	now              func() time.Time
+
+	mutex  sync.Mutex
+	nonces map[string]time.Time
+}
+
+// NewAuthenticator returns the authenticator using the HMAC key in keyFile, the commands of the legacy protocol
+// are accepted only when allowLegacyToken is true.
+func NewAuthenticator(keyFile string, allowLegacyToken bool) *Authenticator {
+	return &Authenticator{
+		keyFile:          keyFile,
+		allowLegacyToken: allowLegacyToken,
+		now:              time.Now,
+		nonces:           make(map[string]time.Time),
+	}
+}
+
+// Authenticate checks the signature or the token of the command according to its protocol version
+func (a *Authenticator) Authenticate(cmd *CommandRPC) error {
+	switch cmd.Version {
+	case ProtocolVersionHMAC:
+		return a.checkSignature(cmd)
+	case 0, ProtocolVersionLegacy:
+		if !a.allowLegacyToken {
+			return fmt.Errorf("the legacy token is not allowed, please upgrade the plugin")
+		}
+		return checkToken(cmd.Token, cmd.Parameters)
+	default:
+		return fmt.Errorf("unsupported protocol version %d", cmd.Version)
+	}
+}
+
+func (a *Authenticator) checkSignature(cmd *CommandRPC) error {
+	now := a.now()
+	skew := now.Sub(time.Unix(cmd.Timestamp, 0))
+	if skew > SignatureWindow || skew < -SignatureWindow {
+		return fmt.Errorf("the timestamp %d is out of the signature window", cmd.Timestamp)
+	}
+	if len(cmd.Nonce) == 0 {
+		return fmt.Errorf("nonce cannot be empty")
+	}
+
+	key, err := os.ReadFile(a.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read key file %s: %v", a.keyFile, err)
+	}
+	signature, err := hex.DecodeString(cmd.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature")
+	}
+	expected, _ := hex.DecodeString(Sign(key, cmd))
+	if !hmac.Equal(signature, expected) {
+		return fmt.Errorf("invalid signature")
+	}
+	if !a.addNonce(cmd.Nonce, now) {
+		return fmt.Errorf("the nonce %s is already used", cmd.Nonce)
+	}
+	return nil
+}
+
+// addNonce remembers the nonce, and returns false if it's already used within the signature window
+func (a *Authenticator) addNonce(nonce string, now time.Time) bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for n, t := range a.nonces {
+		if now.Sub(t) > 2*SignatureWindow {
+			delete(a.nonces, n)
+		}
+	}
+	if _, ok := a.nonces[nonce]; ok {
+		return false
+	}
+	a.nonces[nonce] = now
+	return true
+}
+
+func checkToken(token string, parameters map[string]string) error {
+	ciphertext := utils.Sha256(parameters)
+	original, err := utils.DecryptAESCBC(Secret, token)
+	if err != nil {
+		return err
+	}
+	if ciphertext != original {
+		log.Errorf("Invalid token: %s %s", ciphertext, original)
+		return fmt.Errorf("invalid token")
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package obs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils"
+)
+
+const (
+	testKey       = "test-key"
+	testTimestamp = 1700000000
+	testSecret    = "hnbhacdsfsdfsadn"
+)
+
+var testParameters = map[string]string{"targetPath": "/mnt/test", "bucketName": "bucket"}
+
+func TestCanonicalRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		cmd         *CommandRPC
+		expected    string
+		description string
+	}{
+		{
+			name: "test1",
+			cmd: &CommandRPC{
+				Version:    ProtocolVersionHMAC,
+				Action:     ActionMount,
+				Parameters: testParameters,
+				Timestamp:  testTimestamp,
+				Nonce:      "nonce-1",
+			},
+			expected: "2\nmount\n1700000000\nnonce-1\n" +
+				"39206ea0e467c1cab1ffb6cded06d52b3689b13e7f807ace3d427b09e71c529e",
+			description: "the parameters are hashed in the order of the keys",
+		},
+		{
+			name: "test2",
+			cmd: &CommandRPC{
+				Version:   ProtocolVersionHMAC,
+				Action:    ActionStatus,
+				Timestamp: testTimestamp,
+				Nonce:     "nonce-2",
+			},
+			expected: "2\nstatus\n1700000000\nnonce-2\n" +
+				"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			description: "the parameters are empty",
+		},
+		{
+			name: "test3",
+			cmd: &CommandRPC{
+				Version:    ProtocolVersionHMAC,
+				Action:     ActionMount,
+				Token:      "token",
+				Parameters: testParameters,
+				Timestamp:  testTimestamp,
+				Nonce:      "nonce-1",
+				Signature:  "signature",
+			},
+			expected: "2\nmount\n1700000000\nnonce-1\n" +
+				"39206ea0e467c1cab1ffb6cded06d52b3689b13e7f807ace3d427b09e71c529e",
+			description: "the token and the signature are not signed",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := CanonicalRequest(testCase.cmd)
+			if result != testCase.expected {
+				t.Errorf("expected: %q, got: %q", testCase.expected, result)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         string
+		cmd         *CommandRPC
+		expected    string
+		description string
+	}{
+		{
+			name: "test1",
+			key:  testKey,
+			cmd: &CommandRPC{
+				Version:    ProtocolVersionHMAC,
+				Action:     ActionMount,
+				Parameters: testParameters,
+				Timestamp:  testTimestamp,
+				Nonce:      "nonce-1",
+			},
+			expected:    "4ff71aaa151cbb1fe3e89f8beab780bafd8a0fb9147f99b1e7d926ff69236193",
+			description: "normal",
+		},
+		{
+			name: "test2",
+			key:  testKey,
+			cmd: &CommandRPC{
+				Version:   ProtocolVersionHMAC,
+				Action:    ActionStatus,
+				Timestamp: testTimestamp,
+				Nonce:     "nonce-2",
+			},
+			expected:    "9b38aa3f266a593c000f8ec8a74a24a99dd991699c01ceb070ff2cfa45eddf70",
+			description: "the parameters are empty",
+		},
+		{
+			name: "test3",
+			key:  "other-key",
+			cmd: &CommandRPC{
+				Version:    ProtocolVersionHMAC,
+				Action:     ActionMount,
+				Parameters: testParameters,
+				Timestamp:  testTimestamp,
+				Nonce:      "nonce-1",
+			},
+			expected:    "1e7a7e3dd804d0508fbc52a89af17816fbdccafa4a40fc524c73fe37a07be876",
+			description: "the same command signed by another key",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := Sign([]byte(testCase.key), testCase.cmd)
+			if result != testCase.expected {
+				t.Errorf("expected: %s, got: %s", testCase.expected, result)
+			}
+		})
+	}
+}
+
+func newTestAuthenticator(t *testing.T, allowLegacyToken bool) *Authenticator {
+	keyFile := filepath.Join(t.TempDir(), "connector.key")
+	if err := os.WriteFile(keyFile, []byte(testKey), 0600); err != nil {
+		t.Fatalf("failed to write the key file: %v", err)
+	}
+	a := NewAuthenticator(keyFile, allowLegacyToken)
+	a.now = func() time.Time {
+		return time.Unix(testTimestamp, 0)
+	}
+	return a
+}
+
+func newSignedCommand(timestamp int64, nonce string) *CommandRPC {
+	cmd := &CommandRPC{
+		Version:    ProtocolVersionHMAC,
+		Action:     ActionMount,
+		Parameters: testParameters,
+		Timestamp:  timestamp,
+		Nonce:      nonce,
+	}
+	cmd.Signature = Sign([]byte(testKey), cmd)
+	return cmd
+}
+
+func newLegacyCommand(t *testing.T) *CommandRPC {
+	token, err := utils.EncryptAESCBC(Secret, utils.Sha256(testParameters))
+	if err != nil {
+		t.Fatalf("failed to encrypt the token: %v", err)
+	}
+	return &CommandRPC{Action: ActionMount, Token: token, Parameters: testParameters}
+}
+
+func TestAuthenticate(t *testing.T) {
+	secret := Secret
+	Secret = testSecret
+	defer func() {
+		Secret = secret
+	}()
+
+	tests := []struct {
+		name             string
+		allowLegacyToken bool
+		cmd              func(t *testing.T) *CommandRPC
+		expected         bool
+		description      string
+	}{
+		{
+			name: "test1",
+			cmd: func(t *testing.T) *CommandRPC {
+				return newSignedCommand(testTimestamp, "nonce-1")
+			},
+			expected:    true,
+			description: "normal, signed command",
+		},
+		{
+			name: "test2",
+			cmd: func(t *testing.T) *CommandRPC {
+				return newSignedCommand(testTimestamp-int64(4*time.Minute/time.Second), "nonce-1")
+			},
+			expected:    true,
+			description: "the timestamp is in the past, but within the signature window",
+		},
+		{
+			name: "test3",
+			cmd: func(t *testing.T) *CommandRPC {
+				return newSignedCommand(testTimestamp-int64(6*time.Minute/time.Second), "nonce-1")
+			},
+			expected:    false,
+			description: "the timestamp is before the signature window",
+		},
+		{
+			name: "test4",
+			cmd: func(t *testing.T) *CommandRPC {
+				return newSignedCommand(testTimestamp+int64(6*time.Minute/time.Second), "nonce-1")
+			},
+			expected:    false,
+			description: "the timestamp is after the signature window",
+		},
+		{
+			name: "test5",
+			cmd: func(t *testing.T) *CommandRPC {
+				return newSignedCommand(testTimestamp, "")
+			},
+			expected:    false,
+			description: "the nonce is empty",
+		},
+		{
+			name: "test6",
+			cmd: func(t *testing.T) *CommandRPC {
+				cmd := newSignedCommand(testTimestamp, "nonce-1")
+				cmd.Parameters = map[string]string{"targetPath": "/mnt/other", "bucketName": "bucket"}
+				return cmd
+			},
+			expected:    false,
+			description: "the parameters are changed after the command is signed",
+		},
+		{
+			name: "test7",
+			cmd: func(t *testing.T) *CommandRPC {
+				cmd := newSignedCommand(testTimestamp, "nonce-1")
+				cmd.Signature = "not-hex"
+				return cmd
+			},
+			expected:    false,
+			description: "the signature is not hex encoded",
+		},
+		{
+			name: "test8",
+			cmd: func(t *testing.T) *CommandRPC {
+				cmd := newSignedCommand(testTimestamp, "nonce-1")
+				cmd.Version = 3
+				return cmd
+			},
+			expected:    false,
+			description: "the protocol version is not supported",
+		},
+		{
+			name:             "test9",
+			allowLegacyToken: true,
+			cmd:              newLegacyCommand,
+			expected:         true,
+			description:      "the legacy token is allowed",
+		},
+		{
+			name:        "test10",
+			cmd:         newLegacyCommand,
+			expected:    false,
+			description: "the legacy token is not allowed",
+		},
+		{
+			name:             "test11",
+			allowLegacyToken: true,
+			cmd: func(t *testing.T) *CommandRPC {
+				cmd := newLegacyCommand(t)
+				cmd.Parameters = map[string]string{"targetPath": "/mnt/other", "bucketName": "bucket"}
+				return cmd
+			},
+			expected:    false,
+			description: "the parameters are changed after the legacy token is generated",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			a := newTestAuthenticator(t, testCase.allowLegacyToken)
+			err := a.Authenticate(testCase.cmd(t))
+			if testCase.expected && err != nil {
+				t.Errorf("expected: %v, but got error: %v", testCase.expected, err)
+			}
+			if !testCase.expected && err == nil {
+				t.Errorf("expected: %v, but got no error", testCase.expected)
+			}
+		})
+	}
+}
+
+func TestAuthenticateNonceReplay(t *testing.T) {
+	a := newTestAuthenticator(t, false)
+	tests := []struct {
+		name        string
+		elapsed     time.Duration
+		cmd         *CommandRPC
+		expected    bool
+		description string
+	}{
+		{
+			name:        "test1",
+			cmd:         newSignedCommand(testTimestamp, "nonce-1"),
+			expected:    true,
+			description: "the nonce is used for the first time",
+		},
+		{
+			name:        "test2",
+			cmd:         newSignedCommand(testTimestamp, "nonce-1"),
+			expected:    false,
+			description: "the command is replayed",
+		},
+		{
+			name:        "test3",
+			elapsed:     4 * time.Minute,
+			cmd:         newSignedCommand(testTimestamp+int64(4*time.Minute/time.Second), "nonce-1"),
+			expected:    false,
+			description: "the nonce is reused by a new command within the signature window",
+		},
+		{
+			name:        "test4",
+			cmd:         newSignedCommand(testTimestamp, "nonce-2"),
+			expected:    true,
+			description: "another nonce",
+		},
+		{
+			name:        "test5",
+			elapsed:     11 * time.Minute,
+			cmd:         newSignedCommand(testTimestamp+int64(11*time.Minute/time.Second), "nonce-1"),
+			expected:    true,
+			description: "the nonce is forgotten after twice the signature window",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			a.now = func() time.Time {
+				return time.Unix(testTimestamp, 0).Add(testCase.elapsed)
+			}
+			err := a.Authenticate(testCase.cmd)
+			if testCase.expected && err != nil {
+				t.Errorf("expected: %v, but got error: %v", testCase.expected, err)
+			}
+			if !testCase.expected && err == nil {
+				t.Errorf("expected: %v, but got no error", testCase.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package obs
+
+import (
+	"strconv"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/obs/services"
+)
+
+const (
+	// StorageClassKey in StorageClass parameters, the default storage class of the bucket: STANDARD or WARM
+	StorageClassKey = "storageClass"
+	// MultiAZKey in StorageClass parameters, the bucket is stored in multiple AZs when it's true
+	MultiAZKey = "multiAZ"
+	// VersioningKey in StorageClass parameters, it's rejected since the parallel file systems do not support
+	// versioning.
+	VersioningKey = "versioning"
+	// ExpirationDaysKey in StorageClass parameters, the objects are deleted the days after they are created
+	ExpirationDaysKey = "expirationDays"
+	// TransitionToWarmDaysKey in StorageClass parameters, the objects are transitioned to WARM
+	// the days after they are created
+	TransitionToWarmDaysKey = "transitionToWarmDays"
+	// TransitionToColdDaysKey in StorageClass parameters, it's rejected since the parallel file systems
+	// do not support the COLD storage class.
+	TransitionToColdDaysKey = "transitionToColdDays"
+	// EncryptionKey in StorageClass parameters, the default server-side encryption of the bucket: kms or obs
+	EncryptionKey = "encryption"
+	// KmsKeyIDKey in StorageClass parameters, the KMS key of SSE-KMS, defaults to the default KMS key of OBS
+	KmsKeyIDKey = "kmsKeyId"
+
+	encryptionKms = "kms"
+	encryptionObs = "obs"
+	// sseObsAlgorithm is the algorithm of SSE-OBS
+	sseObsAlgorithm = "AES256"
+
+	lifecycleRuleID = "csi-lifecycle"
+)
+
+type bucketOptions struct {
+	storageClass         obs.StorageClassType
+	availableZone        string
+	expirationDays       int
+	transitionToWarmDays int
+	encryption           string
+	kmsKeyID             string
+}
+
+// parseBucketOptions validates the bucket options in the StorageClass parameters, the buckets are parallel
+// file systems, which support neither versioning nor the COLD storage class.
+func parseBucketOptions(parameters map[string]string) (*bucketOptions, error) {
+	opts := &bucketOptions{}
+
+	if v := parameters[StorageClassKey]; v != "" {
+		storageClass := obs.StorageClassType(v)
+		if storageClass != obs.StorageClassStandard && storageClass != obs.StorageClassWarm {
+			return nil, status.Errorf(codes.InvalidArgument, "%s error, expected STANDARD or WARM, "+
+				"but got %s", StorageClassKey, v)
+		}
+		opts.storageClass = storageClass
+	}
+	for _, key := range []string{VersioningKey, TransitionToColdDaysKey} {
+		if _, ok := parameters[key]; ok {
+			return nil, status.Errorf(codes.InvalidArgument, "%s is not supported by the parallel file systems",
+				key)
+		}
+	}
+
+	multiAZ, err := parseBoolParameter(parameters, MultiAZKey)
+	if err != nil {
+		return nil, err
+	}
+	if multiAZ {
+		opts.availableZone = string(obs.AvailableZoneMultiAz)
+	}
+
+	if opts.expirationDays, err = parseDaysParameter(parameters, ExpirationDaysKey); err != nil {
+		return nil, err
+	}
+	if opts.transitionToWarmDays, err = parseDaysParameter(parameters, TransitionToWarmDaysKey); err != nil {
+		return nil, err
+	}
+	if opts.expirationDays > 0 && opts.expirationDays <= opts.transitionToWarmDays {
+		return nil, status.Errorf(codes.InvalidArgument, "%s must be greater than %s",
+			ExpirationDaysKey, TransitionToWarmDaysKey)
+	}
+
+	opts.encryption = parameters[EncryptionKey]
+	if opts.encryption != "" && opts.encryption != encryptionKms && opts.encryption != encryptionObs {
+		return nil, status.Errorf(codes.InvalidArgument, "%s error, expected %s or %s, but got %s",
+			EncryptionKey, encryptionKms, encryptionObs, opts.encryption)
+	}
+	opts.kmsKeyID = parameters[KmsKeyIDKey]
+	if opts.kmsKeyID != "" && opts.encryption != encryptionKms {
+		return nil, status.Errorf(codes.InvalidArgument, "%s requires %s to be %s",
+			KmsKeyIDKey, EncryptionKey, encryptionKms)
+	}
+	return opts, nil
+}
+
+func parseBoolParameter(parameters map[string]string, key string) (bool, error) {
+	v, ok := parameters[key]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, status.Errorf(codes.InvalidArgument, "%s error, expected a boolean, but got %s", key, v)
+	}
+	return b, nil
+}
+
+func parseDaysParameter(parameters map[string]string, key string) (int, error) {
+	v, ok := parameters[key]
+	if !ok {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(v)
+	if err != nil || days <= 0 {
+		return 0, status.Errorf(codes.InvalidArgument, "%s error, expected a positive integer, but got %s", key, v)
+	}
+	return days, nil
+}
+
+// buildLifecycleRules returns the lifecycle rule of the whole bucket, it's empty if there are no lifecycle options
+func (opts *bucketOptions) buildLifecycleRules() []obs.LifecycleRule {
+	if opts.expirationDays == 0 && opts.transitionToWarmDays == 0 {
+		return nil
+	}
+	rule := obs.LifecycleRule{
+		ID:     lifecycleRuleID,
+		Status: obs.RuleStatusEnabled,
+	}
+	if opts.transitionToWarmDays > 0 {
+		rule.Transitions = append(rule.Transitions, obs.Transition{
+			Days:         opts.transitionToWarmDays,
+			StorageClass: obs.StorageClassWarm,
+		})
+	}
+	if opts.expirationDays > 0 {
+		rule.Expiration = obs.Expiration{Days: opts.expirationDays}
+	}
+	return []obs.LifecycleRule{rule}
+}
+
+// applyBucketOptions applies the options to the bucket right after it's created, the options are applied again
+// when the request is retried and the bucket already exists, because the previous request may fail after
+// the bucket is created. All the options are set with PUT requests, so that they are idempotent.
+func applyBucketOptions(cc *config.CloudCredentials, bucketName string, opts *bucketOptions) error {
+	if opts.storageClass != "" {
+		if err := services.SetBucketStorageClass(cc, bucketName, opts.storageClass); err != nil {
+			return err
+		}
+	}
+	if rules := opts.buildLifecycleRules(); len(rules) > 0 {
+		if err := services.SetBucketLifecycle(cc, bucketName, rules); err != nil {
+			return err
+		}
+	}
+	switch opts.encryption {
+	case encryptionKms:
+		if err := services.SetBucketEncryption(cc, bucketName, encryptionKms, opts.kmsKeyID); err != nil {
+			return err
+		}
+	case encryptionObs:
+		if err := services.SetBucketEncryption(cc, bucketName, sseObsAlgorithm, ""); err != nil {
+			return err
+		}
+	}
+	log.Infof("Successfully applied the options to volume %s", bucketName)
+	return nil
+}
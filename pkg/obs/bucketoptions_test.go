@@ -0,0 +1,197 @@
+package obs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/huaweicloud/huaweicloud-sdk-go-obs/obs"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseBucketOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		parameters  map[string]string
+		expected    *bucketOptions
+		code        codes.Code
+		description string
+	}{
+		{
+			name:        "test1",
+			parameters:  map[string]string{},
+			expected:    &bucketOptions{},
+			description: "no options",
+		},
+		{
+			name: "test2",
+			parameters: map[string]string{
+				StorageClassKey:         "WARM",
+				MultiAZKey:              "true",
+				ExpirationDaysKey:       "365",
+				TransitionToWarmDaysKey: "30",
+				EncryptionKey:           "kms",
+				KmsKeyIDKey:             "kms-id",
+			},
+			expected: &bucketOptions{
+				storageClass:         obs.StorageClassWarm,
+				availableZone:        string(obs.AvailableZoneMultiAz),
+				expirationDays:       365,
+				transitionToWarmDays: 30,
+				encryption:           encryptionKms,
+				kmsKeyID:             "kms-id",
+			},
+			description: "all the options",
+		},
+		{
+			name:        "test3",
+			parameters:  map[string]string{MultiAZKey: "false", EncryptionKey: "obs"},
+			expected:    &bucketOptions{encryption: encryptionObs},
+			description: "single AZ with SSE-OBS",
+		},
+		{
+			name:        "test4",
+			parameters:  map[string]string{StorageClassKey: "COLD"},
+			code:        codes.InvalidArgument,
+			description: "COLD is not supported by the parallel file systems",
+		},
+		{
+			name:        "test5",
+			parameters:  map[string]string{StorageClassKey: "standard"},
+			code:        codes.InvalidArgument,
+			description: "the storage class is case sensitive",
+		},
+		{
+			name:        "test6",
+			parameters:  map[string]string{VersioningKey: "true"},
+			code:        codes.InvalidArgument,
+			description: "versioning is not supported by the parallel file systems",
+		},
+		{
+			name:        "test7",
+			parameters:  map[string]string{TransitionToColdDaysKey: "90"},
+			code:        codes.InvalidArgument,
+			description: "the transition to COLD is not supported by the parallel file systems",
+		},
+		{
+			name:        "test8",
+			parameters:  map[string]string{MultiAZKey: "yes"},
+			code:        codes.InvalidArgument,
+			description: "multiAZ is not a boolean",
+		},
+		{
+			name:        "test9",
+			parameters:  map[string]string{ExpirationDaysKey: "0"},
+			code:        codes.InvalidArgument,
+			description: "the expiration days is not positive",
+		},
+		{
+			name:        "test10",
+			parameters:  map[string]string{TransitionToWarmDaysKey: "thirty"},
+			code:        codes.InvalidArgument,
+			description: "the transition days is not a number",
+		},
+		{
+			name:        "test11",
+			parameters:  map[string]string{ExpirationDaysKey: "30", TransitionToWarmDaysKey: "30"},
+			code:        codes.InvalidArgument,
+			description: "the expiration days is not greater than the transition days",
+		},
+		{
+			name:        "test12",
+			parameters:  map[string]string{EncryptionKey: "aes"},
+			code:        codes.InvalidArgument,
+			description: "unknown encryption",
+		},
+		{
+			name:        "test13",
+			parameters:  map[string]string{EncryptionKey: "obs", KmsKeyIDKey: "kms-id"},
+			code:        codes.InvalidArgument,
+			description: "the KMS key is specified without SSE-KMS",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			opts, err := parseBucketOptions(testCase.parameters)
+			if testCase.code != codes.OK {
+				if status.Code(err) != testCase.code {
+					t.Fatalf("expected code: %v, got error: %v", testCase.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if !reflect.DeepEqual(opts, testCase.expected) {
+				t.Errorf("expected: %+v, got: %+v", testCase.expected, opts)
+			}
+		})
+	}
+}
+
+func TestBuildLifecycleRules(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        *bucketOptions
+		expected    []obs.LifecycleRule
+		description string
+	}{
+		{
+			name:        "test1",
+			opts:        &bucketOptions{storageClass: obs.StorageClassWarm},
+			expected:    nil,
+			description: "no lifecycle options",
+		},
+		{
+			name: "test2",
+			opts: &bucketOptions{expirationDays: 365},
+			expected: []obs.LifecycleRule{
+				{
+					ID:         lifecycleRuleID,
+					Status:     obs.RuleStatusEnabled,
+					Expiration: obs.Expiration{Days: 365},
+				},
+			},
+			description: "expiration only",
+		},
+		{
+			name: "test3",
+			opts: &bucketOptions{transitionToWarmDays: 30},
+			expected: []obs.LifecycleRule{
+				{
+					ID:     lifecycleRuleID,
+					Status: obs.RuleStatusEnabled,
+					Transitions: []obs.Transition{
+						{Days: 30, StorageClass: obs.StorageClassWarm},
+					},
+				},
+			},
+			description: "transition only",
+		},
+		{
+			name: "test4",
+			opts: &bucketOptions{expirationDays: 365, transitionToWarmDays: 30},
+			expected: []obs.LifecycleRule{
+				{
+					ID:     lifecycleRuleID,
+					Status: obs.RuleStatusEnabled,
+					Transitions: []obs.Transition{
+						{Days: 30, StorageClass: obs.StorageClassWarm},
+					},
+					Expiration: obs.Expiration{Days: 365},
+				},
+			},
+			description: "transition and expiration",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			rules := testCase.opts.buildLifecycleRules()
+			if !reflect.DeepEqual(rules, testCase.expected) {
+				t.Errorf("expected: %+v, got: %+v", testCase.expected, rules)
+			}
+		})
+	}
+}
@@ -30,29 +30,52 @@ import (
 )
 
 type controllerServer struct {
+	csi.UnimplementedControllerServer
+
 	Driver *Driver
 }
 
 func (cs *controllerServer) CreateVolume(_ context.Context, req *csi.CreateVolumeRequest) (
 	*csi.CreateVolumeResponse, error) {
-	log.Infof("CreateVolume: called with args %v", protosanitizer.StripSecrets(*req))
-	credentials := cs.Driver.cloud
+	log.Infof("CreateVolume: called with args %v", protosanitizer.StripSecrets(req))
+	credentials, err := getCredentials(cs.Driver.getCloud(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
 
 	volName := req.GetName()
 	if err := createVolumeValidation(volName, req.GetVolumeCapabilities()); err != nil {
 		return nil, err
 	}
 
+	parameters := req.GetParameters()
+	opts, err := parseBucketOptions(parameters)
+	if err != nil {
+		return nil, err
+	}
+
 	if volume, err := services.GetParallelFSBucket(credentials, volName); err != nil && status.Code(err) != codes.NotFound {
 		return nil, err
 	} else if volume != nil {
 		log.Infof("Volume %s existence, skip creating", volName)
+		if err := applyBucketOptions(credentials, volName, opts); err != nil {
+			return nil, err
+		}
+		// The capacity may not be set if the previous request failed to apply the options
+		if requiredBytes := req.GetCapacityRange().GetRequiredBytes(); volume.Capacity == 0 && requiredBytes > 0 {
+			if err := services.SetBucketCapacity(credentials, volName, requiredBytes); err != nil {
+				return nil, err
+			}
+			volume.Capacity = requiredBytes
+		}
 		return buildCreateVolumeResponse(volume), nil
 	}
 
-	parameters := req.GetParameters()
 	acl := obs.AclType(parameters["acl"])
-	if err := services.CreateBucket(credentials, volName, acl); err != nil {
+	if err := services.CreateBucket(credentials, volName, acl, opts.availableZone); err != nil {
+		return nil, err
+	}
+	if err := applyBucketOptions(credentials, volName, opts); err != nil {
 		return nil, err
 	}
 	if req.GetCapacityRange() != nil {
@@ -72,14 +95,17 @@ func (cs *controllerServer) CreateVolume(_ context.Context, req *csi.CreateVolum
 
 func (cs *controllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolumeRequest) (
 	*csi.DeleteVolumeResponse, error) {
-	log.Infof("DeleteVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("DeleteVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	volName := req.GetVolumeId()
 	if len(volName) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Validation failed, volume ID cannot be empty")
 	}
 
-	credentials := cs.Driver.cloud
+	credentials, err := getCredentials(cs.Driver.getCloud(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
 	volume, err := services.GetParallelFSBucket(credentials, volName)
 	if err != nil {
 		if common.IsNotFound(err) {
@@ -108,24 +134,30 @@ func (cs *controllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolum
 
 func (cs *controllerServer) ControllerGetVolume(_ context.Context, req *csi.ControllerGetVolumeRequest) (
 	*csi.ControllerGetVolumeResponse, error) {
-	log.Infof("ControllerGetVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ControllerGetVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Validation failed, volume ID cannot be empty")
 	}
 
-	bucket, err := services.GetParallelFSBucket(cs.Driver.cloud, volumeID)
-	if err != nil {
-		return nil, err
-	}
-
+	// The request has no secrets, the bucket can only be queried with the access keys in the cloud config
+	credentials := cs.Driver.getCloud()
 	response := csi.ControllerGetVolumeResponse{
 		Volume: &csi.Volume{
-			VolumeId:      volumeID,
-			CapacityBytes: bucket.Capacity,
+			VolumeId: volumeID,
 		},
 	}
+	if !hasAccessKeys(credentials) {
+		log.Warningf("No access keys in the cloud config, skip querying the capacity of volume %s", volumeID)
+		return &response, nil
+	}
+
+	bucket, err := services.GetParallelFSBucket(credentials, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	response.Volume.CapacityBytes = bucket.Capacity
 
 	log.Infof("Successfully obtained volume details, volume ID: %s", volumeID)
 	return &response, nil
@@ -143,17 +175,21 @@ func (cs *controllerServer) ControllerUnpublishVolume(_ context.Context, _ *csi.
 
 func (cs *controllerServer) ListVolumes(_ context.Context, req *csi.ListVolumesRequest) (
 	*csi.ListVolumesResponse, error) {
-	log.Infof("ListVolumes: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ListVolumes: called with args %v", protosanitizer.StripSecrets(req))
 
 	if req.MaxEntries < 0 {
 		return nil, status.Errorf(codes.InvalidArgument,
 			"Validation failed, max entries request %v, must not be negative ", req.MaxEntries)
 	}
+	credentials := cs.Driver.getCloud()
+	if !hasAccessKeys(credentials) {
+		return nil, status.Error(codes.FailedPrecondition, "Failed to list volumes, no access keys in the cloud config")
+	}
 	opts := services.ListOpts{
 		Marker: req.StartingToken,
 		Limit:  int(req.MaxEntries),
 	}
-	volumes, err := services.ListBuckets(cs.Driver.cloud, opts)
+	volumes, err := services.ListBuckets(credentials, opts)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
@@ -205,7 +241,7 @@ func (cs *controllerServer) ControllerGetCapabilities(_ context.Context, _ *csi.
 
 func (cs *controllerServer) ValidateVolumeCapabilities(_ context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (
 	*csi.ValidateVolumeCapabilitiesResponse, error) {
-	log.Infof("ValidateVolumeCapabilities: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ValidateVolumeCapabilities: called with args %v", protosanitizer.StripSecrets(req))
 
 	volCapabilities := req.GetVolumeCapabilities()
 	volumeID := req.GetVolumeId()
@@ -216,7 +252,14 @@ func (cs *controllerServer) ValidateVolumeCapabilities(_ context.Context, req *c
 	if len(volumeID) == 0 {
 		return nil, status.Error(codes.InvalidArgument, "Validation failed, volume ID cannot be empty")
 	}
-	if _, err := services.GetParallelFSBucket(cs.Driver.cloud, volumeID); err != nil {
+	credentials, err := getCredentials(cs.Driver.getCloud(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
+	if !hasAccessKeys(credentials) {
+		log.Warningf("No access keys in the secrets or the cloud config, skip checking the existence of volume %s",
+			volumeID)
+	} else if _, err = services.GetParallelFSBucket(credentials, volumeID); err != nil {
 		return nil, err
 	}
 
@@ -244,8 +287,11 @@ func (cs *controllerServer) GetCapacity(_ context.Context, _ *csi.GetCapacityReq
 
 func (cs *controllerServer) ControllerExpandVolume(_ context.Context, req *csi.ControllerExpandVolumeRequest) (
 	*csi.ControllerExpandVolumeResponse, error) {
-	log.Infof("ControllerExpandVolume: called with args %v", protosanitizer.StripSecrets(*req))
-	cc := cs.Driver.cloud
+	log.Infof("ControllerExpandVolume: called with args %v", protosanitizer.StripSecrets(req))
+	cc, err := getCredentials(cs.Driver.getCloud(), req.GetSecrets())
+	if err != nil {
+		return nil, err
+	}
 
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
@@ -0,0 +1,43 @@
+package obs
+
+import (
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+const (
+	// AccessKeyKey in CSI secrets, the access key of the volume
+	AccessKeyKey = "accessKey"
+	// SecretKeyKey in CSI secrets, the secret key of the volume
+	SecretKeyKey = "secretKey"
+	// SecurityTokenKey in CSI secrets, it's required by the temporary access keys
+	SecurityTokenKey = "securityToken"
+)
+
+// getCredentials returns the credentials to access the bucket, the access keys in the CSI secrets take precedence
+// over the access keys in the cloud config, which are used when the secrets have none.
+func getCredentials(cc *config.CloudCredentials, secrets map[string]string) (*config.CloudCredentials, error) {
+	accessKey := secrets[AccessKeyKey]
+	secretKey := secrets[SecretKeyKey]
+	if accessKey == "" && secretKey == "" {
+		return cc, nil
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Validation failed, both %s and %s are required "+
+			"in the secrets", AccessKeyKey, SecretKeyKey)
+	}
+
+	credentials := *cc
+	credentials.Global.AccessKey = accessKey
+	credentials.Global.SecretKey = secretKey
+	credentials.Global.SecurityToken = secrets[SecurityTokenKey]
+	return &credentials, nil
+}
+
+// hasAccessKeys returns whether the credentials have the access keys, the cloud config has none when the temporary
+// access keys are not yet obtained from the metadata service.
+func hasAccessKeys(cc *config.CloudCredentials) bool {
+	return cc.Global.AccessKey != "" && cc.Global.SecretKey != ""
+}
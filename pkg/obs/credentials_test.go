@@ -0,0 +1,146 @@
+package obs
+
+import (
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+func TestGetCredentials(t *testing.T) {
+	cc := &config.CloudCredentials{}
+	cc.Global.Region = "cn-north-4"
+	cc.Global.AccessKey = "config-ak"
+	cc.Global.SecretKey = "config-sk"
+	cc.Global.SecurityToken = "config-token"
+
+	tests := []struct {
+		name          string
+		secrets       map[string]string
+		accessKey     string
+		secretKey     string
+		securityToken string
+		code          codes.Code
+		description   string
+	}{
+		{
+			name:          "test1",
+			secrets:       nil,
+			accessKey:     "config-ak",
+			secretKey:     "config-sk",
+			securityToken: "config-token",
+			description:   "the secrets are empty, use the access keys in the cloud config",
+		},
+		{
+			name:          "test2",
+			secrets:       map[string]string{AccessKeyKey: "secret-ak", SecretKeyKey: "secret-sk"},
+			accessKey:     "secret-ak",
+			secretKey:     "secret-sk",
+			securityToken: "",
+			description:   "the access keys in the secrets take precedence over the cloud config",
+		},
+		{
+			name: "test3",
+			secrets: map[string]string{
+				AccessKeyKey:     "secret-ak",
+				SecretKeyKey:     "secret-sk",
+				SecurityTokenKey: "secret-token",
+			},
+			accessKey:     "secret-ak",
+			secretKey:     "secret-sk",
+			securityToken: "secret-token",
+			description:   "the temporary access keys in the secrets",
+		},
+		{
+			name:        "test4",
+			secrets:     map[string]string{AccessKeyKey: "secret-ak"},
+			code:        codes.InvalidArgument,
+			description: "the secret key is missing",
+		},
+		{
+			name:        "test5",
+			secrets:     map[string]string{SecretKeyKey: "secret-sk"},
+			code:        codes.InvalidArgument,
+			description: "the access key is missing",
+		},
+		{
+			name:          "test6",
+			secrets:       map[string]string{SecurityTokenKey: "secret-token"},
+			accessKey:     "config-ak",
+			secretKey:     "config-sk",
+			securityToken: "config-token",
+			description:   "the security token alone is ignored",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			credentials, err := getCredentials(cc, testCase.secrets)
+			if testCase.code != codes.OK {
+				if status.Code(err) != testCase.code {
+					t.Fatalf("expected code: %v, got error: %v", testCase.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got: %v", err)
+			}
+			if credentials.Global.AccessKey != testCase.accessKey ||
+				credentials.Global.SecretKey != testCase.secretKey ||
+				credentials.Global.SecurityToken != testCase.securityToken {
+				t.Errorf("expected: %s/%s/%s, got: %s/%s/%s", testCase.accessKey, testCase.secretKey,
+					testCase.securityToken, credentials.Global.AccessKey, credentials.Global.SecretKey,
+					credentials.Global.SecurityToken)
+			}
+			if credentials.Global.Region != cc.Global.Region {
+				t.Errorf("expected region: %s, got: %s", cc.Global.Region, credentials.Global.Region)
+			}
+		})
+	}
+
+	if cc.Global.AccessKey != "config-ak" || cc.Global.SecretKey != "config-sk" {
+		t.Errorf("the cloud config is changed: %s/%s", cc.Global.AccessKey, cc.Global.SecretKey)
+	}
+}
+
+func TestHasAccessKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		accessKey   string
+		secretKey   string
+		expected    bool
+		description string
+	}{
+		{
+			name:        "test1",
+			accessKey:   "ak",
+			secretKey:   "sk",
+			expected:    true,
+			description: "both the access key and the secret key are set",
+		},
+		{
+			name:        "test2",
+			expected:    false,
+			description: "the temporary access keys are not yet obtained from the metadata service",
+		},
+		{
+			name:        "test3",
+			accessKey:   "ak",
+			expected:    false,
+			description: "the secret key is missing",
+		},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			cc := &config.CloudCredentials{}
+			cc.Global.AccessKey = testCase.accessKey
+			cc.Global.SecretKey = testCase.secretKey
+			if v := hasAccessKeys(cc); v != testCase.expected {
+				t.Errorf("expected: %v, got: %v", testCase.expected, v)
+			}
+		})
+	}
+}
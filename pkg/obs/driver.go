@@ -19,6 +19,8 @@ package obs
 import (
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
@@ -43,6 +45,12 @@ type Driver struct {
 	endpoint string
 	cloud    *config.CloudCredentials
 
+	// baseCloud is the cloud config, and cloud is replaced by the temporary access keys before they expire
+	// when the credential source is agency or metadata.
+	baseCloud           *config.CloudCredentials
+	credentialExpiresAt time.Time
+	credentialMutex     sync.RWMutex
+
 	ids *identityServer
 	cs  *controllerServer
 	ns  *nodeServer
@@ -58,6 +66,7 @@ func NewDriver(endpoint string, cloud *config.CloudCredentials) *Driver {
 	d.version = fmt.Sprintf("%s@%s", version.Version, specVersion)
 	d.endpoint = endpoint
 	d.cloud = cloud
+	d.baseCloud = cloud
 
 	log.Infof("Driver: %s, Version: %s, CSI Spec version: %s", d.name, version.Version, specVersion)
 
@@ -81,6 +90,7 @@ func NewDriver(endpoint string, cloud *config.CloudCredentials) *Driver {
 
 	d.AddNodeServiceCapabilities([]csi.NodeServiceCapability_RPC_Type{
 		csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
+		csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
 	})
 
 	d.ids = &identityServer{Driver: d}
@@ -155,7 +165,18 @@ func (d *Driver) SetupDriver(mount mounts.IMount, metadata metadatas.IMetadata,
 	d.ns.MountClient = mountClient
 }
 
+func (d *Driver) getCloud() *config.CloudCredentials {
+	d.credentialMutex.RLock()
+	defer d.credentialMutex.RUnlock()
+	return d.cloud
+}
+
 func (d *Driver) Run() {
+	if isTemporaryCredentialSource(d.baseCloud) {
+		d.refreshCredentials()
+		go d.runCredentialRotation()
+	}
+
 	s := NewNonBlockingGRPCServer()
 	s.Start(d.endpoint, d.ids, d.cs, d.ns)
 	s.Wait()
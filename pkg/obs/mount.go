@@ -2,9 +2,12 @@ package obs
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -13,31 +16,98 @@ import (
 
 const (
 	ActionMount string = "mount"
+	// ActionRefreshCredential replaces the passwd files of the mounts whose credentials are rotated
+	ActionRefreshCredential string = "refreshCredential"
+	// ActionUnmount unmounts the obsfs mount and removes it from the mount table of the connector
+	ActionUnmount string = "unmount"
+	// ActionStatus returns the MountStatus of the obsfs mount
+	ActionStatus string = "status"
+	// ActionList returns the MountStatus of all the obsfs mounts in the mount table of the connector
+	ActionList string = "list"
+
+	// PasswdDir keeps the passwd files of the mounts using the temporary access keys, the files are kept
+	// for the lifetime of the mounts, so that they can be replaced before the access keys expire.
+	PasswdDir = "/var/lib/csi/passwd"
 )
 
 type CommandRPC struct {
+	// Version is the protocol version, it's empty in the commands of the legacy protocol
+	Version    int `json:",omitempty"`
 	Action     string
 	Token      string
 	Parameters map[string]string
+
+	// Timestamp, Nonce and Signature authenticate the commands since ProtocolVersionHMAC
+	Timestamp int64  `json:",omitempty"`
+	Nonce     string `json:",omitempty"`
+	Signature string `json:",omitempty"`
+}
+
+type ResponseBody struct {
+	Data string
+}
+
+// MountStatus is the status of an obsfs mount started by the connector
+type MountStatus struct {
+	BucketName string
+	TargetPath string
+	// Pid is the obsfs process serving the mount, it's 0 if the process is dead
+	Pid     int
+	Mounted bool
+	// Connected is false if the mount point exists but the FUSE process is dead
+	Connected bool
+}
+
+// GetPasswdFile returns the passwd file of the mount whose credentials are rotated
+func GetPasswdFile(targetPath string) string {
+	sum := sha256.Sum256([]byte(targetPath))
+	return filepath.Join(PasswdDir, hex.EncodeToString(sum[:]))
 }
 
 func sendCommand(cmd CommandRPC, mountClient http.Client) error {
+	_, err := queryCommand(cmd, mountClient)
+	return err
+}
+
+// queryCommand sends the command to the connector, and returns the data in the response
+func queryCommand(cmd CommandRPC, mountClient http.Client) (string, error) {
 	marshal, err := json.Marshal(cmd)
 	if err != nil {
-		return err
+		return "", err
 	}
 	log.Infof("Start sending command: %s", string(marshal))
 	response, err := mountClient.Post("http://unix", "application/json", bytes.NewReader(marshal))
 	if err != nil {
-		return status.Errorf(codes.Internal, "Failed to post command, err: %v", err)
+		return "", status.Errorf(codes.Internal, "Failed to post command, err: %v", err)
 	}
 	defer response.Body.Close()
+	respBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to read responseBody, err: %v", err)
+	}
 	if response.StatusCode != http.StatusOK {
-		respBody, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return status.Errorf(codes.Internal, "Failed to read responseBody, err: %v", err)
-		}
-		return status.Errorf(codes.Internal, "Failed to execute the command, body: %v", string(respBody))
+		return "", status.Errorf(codes.Internal, "Failed to execute the command, body: %v", string(respBody))
+	}
+	var body ResponseBody
+	if err = json.Unmarshal(respBody, &body); err != nil {
+		return "", status.Errorf(codes.Internal, "Failed to parse responseBody, err: %v", err)
+	}
+	return body.Data, nil
+}
+
+// getMountStatus returns the status of the obsfs mount at the target path from the connector
+func getMountStatus(targetPath string, mountClient http.Client) (*MountStatus, error) {
+	commandRPC, err := newCommandRPC(mountClient, ActionStatus, map[string]string{"targetPath": targetPath})
+	if err != nil {
+		return nil, err
+	}
+	data, err := queryCommand(*commandRPC, mountClient)
+	if err != nil {
+		return nil, err
+	}
+	var mountStatus MountStatus
+	if err = json.Unmarshal([]byte(data), &mountStatus); err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed to parse the mount status, err: %v", err)
 	}
-	return nil
+	return &mountStatus, nil
 }
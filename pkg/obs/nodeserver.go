@@ -34,13 +34,15 @@ import (
 	log "k8s.io/klog/v2"
 	utilpath "k8s.io/utils/path"
 
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/obs/services"
-	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils"
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils/metadatas"
 	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/utils/mounts"
 )
 
 type nodeServer struct {
+	csi.UnimplementedNodeServer
+
 	Driver      *Driver
 	Mount       mounts.IMount
 	Metadata    metadatas.IMetadata
@@ -55,19 +57,19 @@ const (
 
 func (ns *nodeServer) NodeStageVolume(_ context.Context, req *csi.NodeStageVolumeRequest) (
 	*csi.NodeStageVolumeResponse, error) {
-	log.Infof("NodeStageVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeStageVolume: called with args %v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
 func (ns *nodeServer) NodeUnstageVolume(_ context.Context, req *csi.NodeUnstageVolumeRequest) (
 	*csi.NodeUnstageVolumeResponse, error) {
-	log.Infof("NodeUnstageVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeUnstageVolume: called with args %v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
 func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishVolumeRequest) (
 	*csi.NodePublishVolumeResponse, error) {
-	log.Infof("NodePublishVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodePublishVolume: called with args %v", protosanitizer.StripSecrets(req))
 	capability := req.GetVolumeCapability()
 	volumeID := req.GetVolumeId()
 	targetPath := req.GetTargetPath()
@@ -75,7 +77,11 @@ func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishV
 		return nil, err
 	}
 
-	credentials := ns.Driver.cloud
+	secrets := req.GetSecrets()
+	credentials, err := getCredentials(ns.Driver.getCloud(), secrets)
+	if err != nil {
+		return nil, err
+	}
 	volume, err := services.GetParallelFSBucket(credentials, volumeID)
 	if err != nil {
 		return nil, err
@@ -94,13 +100,22 @@ func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishV
 		return nil, status.Errorf(codes.Internal, "Failed to make dir: %s, error: %v", targetPath, err)
 	}
 
+	// The passwd file of the mount is kept when the temporary access keys of the driver are used,
+	// so that the connector can replace it before the access keys expire.
+	rotation := secrets[AccessKeyKey] == "" && isTemporaryCredentialSource(credentials)
 	credentialFile := fmt.Sprintf("%s/%s", credentialDir, uuid.New().String())
-	accessKey := ns.Driver.cloud.Global.AccessKey
-	secretKey := ns.Driver.cloud.Global.SecretKey
-	if err := createCredentialFile(accessKey, secretKey, credentialFile); err != nil {
+	if rotation {
+		credentialFile = GetPasswdFile(targetPath)
+	}
+	accessKey := credentials.Global.AccessKey
+	secretKey := credentials.Global.SecretKey
+	securityToken := credentials.Global.SecurityToken
+	if err := createCredentialFile(accessKey, secretKey, securityToken, credentialFile); err != nil {
 		return nil, err
 	}
-	defer deleteCredentialFile(credentialFile)
+	if !rotation {
+		defer deleteCredentialFile(credentialFile)
+	}
 
 	mountFlags := []string{"big_writes", "max_write=131072", "use_ino"}
 	if mnt := req.GetVolumeCapability().GetMount(); mnt != nil {
@@ -115,22 +130,22 @@ func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishV
 	parameters := map[string]string{
 		"bucketName": volume.BucketName,
 		"targetPath": targetPath,
-		"region":     ns.Driver.cloud.Global.Region,
-		"cloud":      ns.Driver.cloud.Global.Cloud,
+		"region":     credentials.Global.Region,
+		"cloud":      credentials.Global.Cloud,
 		"credential": credentialFile,
 		"mountFlags": "-o " + strings.Join(mountFlags, " -o "),
 	}
-	ciphertext := utils.Sha256(parameters)
-	token, err := utils.EncryptAESCBC(Secret, ciphertext)
+	if rotation {
+		parameters["credentialRotation"] = "true"
+	}
+	commandRPC, err := newCommandRPC(ns.MountClient, ActionMount, parameters)
 	if err != nil {
 		return nil, err
 	}
-	commandRPC := CommandRPC{
-		Action:     ActionMount,
-		Token:      token,
-		Parameters: parameters,
-	}
-	if err := sendCommand(commandRPC, ns.MountClient); err != nil {
+	if err := sendCommand(*commandRPC, ns.MountClient); err != nil {
+		if rotation {
+			deleteCredentialFile(credentialFile)
+		}
 		return nil, status.Errorf(codes.Internal, "Failed to mount %s at %s: %v",
 			volume.BucketName, targetPath, err)
 	}
@@ -144,7 +159,7 @@ func deleteCredentialFile(credentialFile string) {
 	}
 }
 
-func createCredentialFile(accessKey, secretKey, credentialFile string) error {
+func createCredentialFile(accessKey, secretKey, securityToken, credentialFile string) error {
 	if err := os.MkdirAll(path.Dir(credentialFile), os.ModePerm); err != nil {
 		return status.Errorf(codes.Internal, "Failed to make dir: %s, error: %v", credentialFile, err)
 	}
@@ -156,6 +171,9 @@ func createCredentialFile(accessKey, secretKey, credentialFile string) error {
 	defer writer.Close()
 
 	credentialInfo := accessKey + ":" + secretKey
+	if securityToken != "" {
+		credentialInfo += ":" + securityToken
+	}
 	_, err = fmt.Fprintln(writer, credentialInfo)
 	return err
 }
@@ -175,7 +193,7 @@ func nodePublishValidation(capability *csi.VolumeCapability, volumeID string, ta
 
 func (ns *nodeServer) NodeUnpublishVolume(_ context.Context, req *csi.NodeUnpublishVolumeRequest) (
 	*csi.NodeUnpublishVolumeResponse, error) {
-	log.Infof("NodeUnpublishVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeUnpublishVolume: called with args %v", protosanitizer.StripSecrets(req))
 	volumeID := req.GetVolumeId()
 	targetPath := req.GetTargetPath()
 	if len(volumeID) == 0 {
@@ -191,19 +209,35 @@ func (ns *nodeServer) NodeUnpublishVolume(_ context.Context, req *csi.NodeUnpubl
 	}
 	if notMnt {
 		log.Infof("NodeUnpublishVolume: %s has already uMounted", targetPath)
+		deleteCredentialFile(GetPasswdFile(targetPath))
 		return &csi.NodeUnpublishVolumeResponse{}, nil
 	}
-	if err := ns.Mount.UnmountPath(targetPath); err != nil {
+	if err := ns.unmount(targetPath); err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to unmount target %q: %v", targetPath, err)
 	}
+	deleteCredentialFile(GetPasswdFile(targetPath))
 	log.Infof("NodeUnpublishVolume: unmount volume %s on %s successfully", volumeID, targetPath)
 	return &csi.NodeUnpublishVolumeResponse{}, nil
 }
 
+// unmount unmounts the target path through the connector, which owns the obsfs process and the mount table,
+// the target path is unmounted directly if the connector does not support the unmount action.
+func (ns *nodeServer) unmount(targetPath string) error {
+	commandRPC, err := newCommandRPC(ns.MountClient, ActionUnmount, map[string]string{"targetPath": targetPath})
+	if err != nil {
+		return err
+	}
+	if err = sendCommand(*commandRPC, ns.MountClient); err == nil {
+		return nil
+	}
+	log.Warningf("Failed to unmount %s through the connector, unmount it directly: %v", targetPath, err)
+	return ns.Mount.UnmountPath(targetPath)
+}
+
 func (ns *nodeServer) NodeGetInfo(_ context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
-	log.Infof("NodeGetInfo called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetInfo called with request %v", protosanitizer.StripSecrets(req))
 
-	idc := ns.Driver.cloud.Global.Idc
+	idc := ns.Driver.getCloud().Global.Idc
 	if idc {
 		log.Info("IDC is %v. volume will be mounted directly \n", idc)
 		macAddress, err := getMACAddress()
@@ -228,7 +262,7 @@ func (ns *nodeServer) NodeGetInfo(_ context.Context, req *csi.NodeGetInfoRequest
 
 func (ns *nodeServer) NodeGetCapabilities(_ context.Context, req *csi.NodeGetCapabilitiesRequest) (
 	*csi.NodeGetCapabilitiesResponse, error) {
-	log.Infof("NodeGetCapabilities called with req: %#v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetCapabilities called with req: %#v", protosanitizer.StripSecrets(req))
 
 	return &csi.NodeGetCapabilitiesResponse{
 		Capabilities: ns.Driver.nscap,
@@ -237,7 +271,7 @@ func (ns *nodeServer) NodeGetCapabilities(_ context.Context, req *csi.NodeGetCap
 
 func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeStatsRequest) (
 	*csi.NodeGetVolumeStatsResponse, error) {
-	log.Infof("NodeGetVolumeStats: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetVolumeStats: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	volumePath := req.GetVolumePath()
@@ -245,6 +279,23 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 		return nil, err
 	}
 
+	// The FUSE process of the mount may be dead, which is reported as the abnormal volume condition
+	if mountStatus, err := getMountStatus(volumePath, ns.MountClient); err != nil {
+		log.Warningf("Failed to get the mount status of %s: %v", volumePath, err)
+	} else if mountStatus.Mounted && !mountStatus.Connected {
+		// The usage is unknown, it's reported as zero since kubelet requires the usage in the response
+		return &csi.NodeGetVolumeStatsResponse{
+			Usage: []*csi.VolumeUsage{
+				{Unit: csi.VolumeUsage_BYTES},
+				{Unit: csi.VolumeUsage_INODES},
+			},
+			VolumeCondition: &csi.VolumeCondition{
+				Abnormal: true,
+				Message:  fmt.Sprintf("The obsfs process of %s is not running", volumePath),
+			},
+		}, nil
+	}
+
 	stats, err := ns.Mount.GetDeviceStats(volumePath)
 	if err != nil {
 		return nil, status.Errorf(codes.Unknown, "Failed to get stats by path: %s", err)
@@ -252,19 +303,21 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 	log.Infof("NodeGetVolumeStats: stats info :%s", protosanitizer.StripSecrets(*stats))
 	capacity, usedBytes := stats.TotalBytes, stats.UsedBytes
 
-	bucket, err := services.GetParallelFSBucket(ns.Driver.cloud, volumeID)
-	if err != nil {
-		return nil, err
-	}
-	if bucket.Capacity != 0 {
-		capacity = bucket.Capacity
-	}
-	used, _, err := services.GetBucketStorage(ns.Driver.cloud, volumeID)
-	if err != nil {
-		return nil, err
-	}
-	if used != 0 {
-		usedBytes = used
+	// The request has no secrets, the bucket of a volume published with the access keys in the secrets may not be
+	// accessible by the access keys in the cloud config, the stats of the mount are used then.
+	credentials := ns.Driver.getCloud()
+	if !hasAccessKeys(credentials) {
+		log.Warningf("No access keys in the cloud config, use the stats of the mount %s", volumePath)
+	} else if bucketCapacity, bucketUsed, err := getBucketStats(credentials, volumeID); err != nil {
+		log.Warningf("Failed to get the stats of bucket %s, use the stats of the mount %s: %v",
+			volumeID, volumePath, err)
+	} else {
+		if bucketCapacity != 0 {
+			capacity = bucketCapacity
+		}
+		if bucketUsed != 0 {
+			usedBytes = bucketUsed
+		}
 	}
 
 	return &csi.NodeGetVolumeStatsResponse{
@@ -275,6 +328,19 @@ func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolu
 	}, nil
 }
 
+// getBucketStats returns the capacity and the used bytes of the bucket
+func getBucketStats(cc *config.CloudCredentials, bucketName string) (int64, int64, error) {
+	bucket, err := services.GetParallelFSBucket(cc, bucketName)
+	if err != nil {
+		return 0, 0, err
+	}
+	used, _, err := services.GetBucketStorage(cc, bucketName)
+	if err != nil {
+		return 0, 0, err
+	}
+	return bucket.Capacity, used, nil
+}
+
 func (ns *nodeServer) NodeExpandVolume(_ context.Context, _ *csi.NodeExpandVolumeRequest) (
 	*csi.NodeExpandVolumeResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "")
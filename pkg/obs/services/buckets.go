@@ -86,7 +86,9 @@ func IsParallelFSBucket(FSStatus obs.FSStatusType) bool {
 	return FSStatus == obs.FSStatusEnabled
 }
 
-func CreateBucket(c *config.CloudCredentials, bucketName string, acl obs.AclType) error {
+// CreateBucket creates a parallel file system, the availableZone is 3az for the multi-AZ redundancy,
+// or empty for the single-AZ redundancy.
+func CreateBucket(c *config.CloudCredentials, bucketName string, acl obs.AclType, availableZone string) error {
 	client, err := getObsClient(c)
 	if err != nil {
 		return err
@@ -95,6 +97,7 @@ func CreateBucket(c *config.CloudCredentials, bucketName string, acl obs.AclType
 		Bucket:            bucketName,
 		ACL:               acl,
 		IsFSFileInterface: true,
+		AvailableZone:     availableZone,
 		BucketLocation:    obs.BucketLocation{Location: c.Global.Region},
 	}
 	if _, err = client.CreateBucket(input); err == nil {
@@ -106,6 +109,60 @@ func CreateBucket(c *config.CloudCredentials, bucketName string, acl obs.AclType
 	return status.Errorf(codes.Internal, "Error creating OBS instance %s: %v", bucketName, err)
 }
 
+// SetBucketStorageClass sets the default storage class of the objects in the bucket
+func SetBucketStorageClass(c *config.CloudCredentials, bucketName string, storageClass obs.StorageClassType) error {
+	client, err := getObsClient(c)
+	if err != nil {
+		return err
+	}
+	input := &obs.SetBucketStoragePolicyInput{
+		Bucket:              bucketName,
+		BucketStoragePolicy: obs.BucketStoragePolicy{StorageClass: storageClass},
+	}
+	if _, err = client.SetBucketStoragePolicy(input); err != nil {
+		return status.Errorf(codes.Internal, "Error setting OBS instance %s storage class to %s: %v",
+			bucketName, storageClass, err)
+	}
+	return nil
+}
+
+// SetBucketLifecycle replaces the lifecycle rules of the bucket
+func SetBucketLifecycle(c *config.CloudCredentials, bucketName string, rules []obs.LifecycleRule) error {
+	client, err := getObsClient(c)
+	if err != nil {
+		return err
+	}
+	input := &obs.SetBucketLifecycleConfigurationInput{
+		Bucket:                      bucketName,
+		BucketLifecyleConfiguration: obs.BucketLifecyleConfiguration{LifecycleRules: rules},
+	}
+	if _, err = client.SetBucketLifecycleConfiguration(input); err != nil {
+		return status.Errorf(codes.Internal, "Error setting OBS instance %s lifecycle rules: %v", bucketName, err)
+	}
+	return nil
+}
+
+// SetBucketEncryption sets the default server-side encryption of the bucket, the algorithm is kms for SSE-KMS,
+// or AES256 for SSE-OBS, and the kmsKeyID is only used by SSE-KMS.
+func SetBucketEncryption(c *config.CloudCredentials, bucketName, algorithm, kmsKeyID string) error {
+	client, err := getObsClient(c)
+	if err != nil {
+		return err
+	}
+	input := &obs.SetBucketEncryptionInput{
+		Bucket: bucketName,
+		BucketEncryptionConfiguration: obs.BucketEncryptionConfiguration{
+			SSEAlgorithm:   algorithm,
+			KMSMasterKeyID: kmsKeyID,
+		},
+	}
+	if _, err = client.SetBucketEncryption(input); err != nil {
+		return status.Errorf(codes.Internal, "Error setting OBS instance %s encryption to %s: %v",
+			bucketName, algorithm, err)
+	}
+	return nil
+}
+
 func CleanBucket(c *config.CloudCredentials, bucketName string) error {
 	if err := AbortMultipartUpload(c, bucketName); err != nil {
 		return err
@@ -279,6 +336,10 @@ func min(i int, j int) int {
 }
 
 func getObsClient(c *config.CloudCredentials) (*obs.ObsClient, error) {
+	if c.CloudClient == nil {
+		return nil, status.Error(codes.Unavailable, "The cloud client is not initialized, "+
+			"the temporary access keys may not be obtained yet")
+	}
 	httpClient := c.CloudClient.HTTPClient
 	httpClientConfigure := obs.WithHttpClient(&httpClient)
 
@@ -290,7 +351,11 @@ func getObsClient(c *config.CloudCredentials) (*obs.ObsClient, error) {
 	}
 	endpoint := fmt.Sprintf("%s.%s.%s", obsName, c.Global.Region, c.Global.Cloud)
 
-	client, err := obs.New(c.Global.AccessKey, c.Global.SecretKey, endpoint, httpClientConfigure, userAgentConfigure)
+	// The security token is empty unless the temporary access keys are used
+	securityTokenConfigure := obs.WithSecurityToken(c.Global.SecurityToken)
+
+	client, err := obs.New(c.Global.AccessKey, c.Global.SecretKey, endpoint, httpClientConfigure,
+		userAgentConfigure, securityTokenConfigure)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Error initializing OBS client: %v", err)
 	}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/chnsz/golangsdk"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+)
+
+// securityKeyURL is the metadata service URL of the temporary access keys of the agency bound to the ECS
+const securityKeyURL = "http://169.254.169.254/openstack/latest/securitykey"
+
+// TemporaryCredential is the temporary access keys returned by IAM or the metadata service
+type TemporaryCredential struct {
+	Access        string    `json:"access"`
+	Secret        string    `json:"secret"`
+	SecurityToken string    `json:"securitytoken"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+// CreateAgencyCredential obtains the temporary access keys by assuming the agency
+func CreateAgencyCredential(c *config.CloudCredentials, domainName, agencyName string, duration int) (
+	*TemporaryCredential, error) {
+	client, err := c.IamV30Client()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Failed create IAM V3.0 client: %s", err)
+	}
+
+	body := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"identity": map[string]interface{}{
+				"methods": []string{"assume_role"},
+				"assume_role": map[string]interface{}{
+					"domain_name":      domainName,
+					"agency_name":      agencyName,
+					"duration_seconds": duration,
+				},
+			},
+		},
+	}
+	var rst struct {
+		Credential TemporaryCredential `json:"credential"`
+	}
+	_, err = client.Post(client.ServiceURL("OS-CREDENTIAL", "securitytokens"), body, &rst, &golangsdk.RequestOpts{
+		OkCodes: []int{201},
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Error assuming the agency %s of domain %s: %s",
+			agencyName, domainName, err)
+	}
+	return &rst.Credential, nil
+}
+
+// GetMetadataCredential obtains the temporary access keys of the agency bound to the ECS from the metadata service
+func GetMetadataCredential() (*TemporaryCredential, error) {
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(securityKeyURL)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "Error fetching %s: %v", securityKeyURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, status.Errorf(codes.Internal, "Unexpected status code when reading %s: %s, "+
+			"make sure an agency is bound to the ECS", securityKeyURL, resp.Status)
+	}
+
+	var rst struct {
+		Credential TemporaryCredential `json:"credential"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&rst); err != nil {
+		return nil, status.Errorf(codes.Internal, "Error parsing the temporary access keys: %v", err)
+	}
+	if rst.Credential.Access == "" || rst.Credential.SecurityToken == "" {
+		return nil, status.Errorf(codes.Internal, "Invalid temporary access keys from %s", securityKeyURL)
+	}
+	return &rst.Credential, nil
+}
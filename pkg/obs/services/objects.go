@@ -112,3 +112,24 @@ func ListMultipartUploads(c *config.CloudCredentials, bucketName string) (*obs.L
 	}
 	return output, status.Errorf(codes.Internal, "Error getting OBS instance %s upload list: %v", bucketName, err)
 }
+
+// GetObject returns the content of the object, the body of the output must be closed by the caller
+func GetObject(c *config.CloudCredentials, bucketName, key string) (*obs.GetObjectOutput, error) {
+	client, err := getObsClient(c)
+	if err != nil {
+		return nil, err
+	}
+	input := &obs.GetObjectInput{}
+	input.Bucket = bucketName
+	input.Key = key
+	output, err := client.GetObject(input)
+	if err == nil {
+		return output, nil
+	}
+	if obsError, ok := err.(obs.ObsError); ok && obsError.StatusCode == http.StatusNotFound {
+		return nil, status.Errorf(codes.NotFound, "Error, the object %s of OBS instance %s does not exist: %v",
+			key, bucketName, err)
+	}
+	return nil, status.Errorf(codes.Internal, "Error getting the object %s of OBS instance %s: %v",
+		key, bucketName, err)
+}
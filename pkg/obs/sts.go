@@ -0,0 +1,114 @@
+package obs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	log "k8s.io/klog/v2"
+
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/config"
+	"github.com/huaweicloud/huaweicloud-csi-driver/pkg/obs/services"
+)
+
+const (
+	// credentialRefreshAhead is how long before expiry the temporary access keys are refreshed
+	credentialRefreshAhead  = 15 * time.Minute
+	credentialCheckInterval = time.Minute
+)
+
+func isTemporaryCredentialSource(cc *config.CloudCredentials) bool {
+	source := cc.Obs.CredentialSource
+	return source == config.CredentialSourceAgency || source == config.CredentialSourceMetadata
+}
+
+func getTemporaryCredential(cc *config.CloudCredentials) (*services.TemporaryCredential, error) {
+	if cc.Obs.CredentialSource == config.CredentialSourceMetadata {
+		return services.GetMetadataCredential()
+	}
+	return services.CreateAgencyCredential(cc, cc.Obs.AgencyDomainName, cc.Obs.AgencyName,
+		cc.Obs.CredentialDuration)
+}
+
+// refreshCredentials replaces the access keys of the driver with new temporary access keys
+// when the current ones are about to expire, and returns whether they are refreshed.
+func (d *Driver) refreshCredentials() bool {
+	if time.Until(d.credentialExpiresAt) > credentialRefreshAhead {
+		return false
+	}
+
+	credential, err := getTemporaryCredential(d.baseCloud)
+	if err != nil {
+		log.Errorf("Failed to obtain the temporary access keys from %s: %v", d.baseCloud.Obs.CredentialSource, err)
+		return false
+	}
+	cloud := *d.baseCloud
+	cloud.Global.AccessKey = credential.Access
+	cloud.Global.SecretKey = credential.Secret
+	cloud.Global.SecurityToken = credential.SecurityToken
+	if err = cloud.Validate(); err != nil {
+		log.Errorf("Failed to initialize the cloud client with the temporary access keys: %v", err)
+		return false
+	}
+
+	d.credentialMutex.Lock()
+	d.cloud = &cloud
+	d.credentialExpiresAt = credential.ExpiresAt
+	d.credentialMutex.Unlock()
+	log.Infof("Successfully refreshed the temporary access keys, expires at %s", credential.ExpiresAt)
+	return true
+}
+
+// runCredentialRotation refreshes the temporary access keys before they expire, and rotates the credentials
+// of the mounts on the node. The mounts are rotated after the driver starts as well, because the credentials
+// of the mounts made by the previous driver are unknown.
+func (d *Driver) runCredentialRotation() {
+	ticker := time.NewTicker(credentialCheckInterval)
+	defer ticker.Stop()
+
+	rotated := false
+	for range ticker.C {
+		if d.refreshCredentials() {
+			rotated = false
+		}
+		if rotated {
+			continue
+		}
+		if err := d.ns.rotateMountCredentials(); err != nil {
+			log.Errorf("Failed to rotate the credentials of the mounts: %v", err)
+			continue
+		}
+		rotated = true
+	}
+}
+
+// rotateMountCredentials replaces the passwd files of the mounts using the temporary access keys
+// through the connector, it's ignored if there are no such mounts on the node.
+func (ns *nodeServer) rotateMountCredentials() error {
+	entries, err := os.ReadDir(PasswdDir)
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+
+	cc := ns.Driver.getCloud()
+	credentialFile := fmt.Sprintf("%s/%s", credentialDir, uuid.New().String())
+	err = createCredentialFile(cc.Global.AccessKey, cc.Global.SecretKey, cc.Global.SecurityToken, credentialFile)
+	if err != nil {
+		return err
+	}
+	defer deleteCredentialFile(credentialFile)
+
+	parameters := map[string]string{"credential": credentialFile}
+	commandRPC, err := newCommandRPC(ns.MountClient, ActionRefreshCredential, parameters)
+	if err != nil {
+		return err
+	}
+	if err = sendCommand(*commandRPC, ns.MountClient); err != nil {
+		return status.Errorf(codes.Internal, "Failed to refresh the credentials of %d mounts: %v", len(entries), err)
+	}
+	log.Infof("Successfully rotated the credentials of %d mounts", len(entries))
+	return nil
+}
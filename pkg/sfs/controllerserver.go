@@ -34,12 +34,14 @@ import (
 )
 
 type controllerServer struct {
+	csi.UnimplementedControllerServer
+
 	Driver *SfsDriver
 }
 
 func (cs *controllerServer) CreateVolume(_ context.Context, req *csi.CreateVolumeRequest) (
 	*csi.CreateVolumeResponse, error) {
-	log.Infof("CreateVolume called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("CreateVolume called with request %v", protosanitizer.StripSecrets(req))
 	client, err := cs.Driver.cloud.SFSV2Client()
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "Failed to create SFS v2 client: %v", err)
@@ -121,7 +123,7 @@ func checkVolumeExist(client *golangsdk.ServiceClient, name string, sizeInGiB in
 }
 
 func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
-	klog.V(2).Infof("DeleteVolume called with request %v", *req)
+	klog.V(2).Infof("DeleteVolume called with request %v", req)
 
 	volID := req.GetVolumeId()
 	if len(volID) == 0 {
@@ -175,7 +177,7 @@ func (cs *controllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnap
 // ControllerGetCapabilities implements the default GRPC callout.
 // Default supports all capabilities
 func (cs *controllerServer) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
-	klog.V(2).Infof("ControllerGetCapabilities called with request %v", *req)
+	klog.V(2).Infof("ControllerGetCapabilities called with request %v", req)
 
 	return &csi.ControllerGetCapabilitiesResponse{
 		Capabilities: cs.Driver.cscap,
@@ -183,7 +185,7 @@ func (cs *controllerServer) ControllerGetCapabilities(ctx context.Context, req *
 }
 
 func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
-	klog.V(2).Infof("ValidateVolumeCapabilities called with args %+v", *req)
+	klog.V(2).Infof("ValidateVolumeCapabilities called with args %+v", req)
 
 	reqVolCap := req.GetVolumeCapabilities()
 
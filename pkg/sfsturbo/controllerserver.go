@@ -35,6 +35,8 @@ import (
 )
 
 type controllerServer struct {
+	csi.UnimplementedControllerServer
+
 	Driver *SfsTurboDriver
 }
 
@@ -54,7 +56,7 @@ const (
 
 func (cs *controllerServer) CreateVolume(_ context.Context, req *csi.CreateVolumeRequest) (
 	*csi.CreateVolumeResponse, error) {
-	log.Infof("CreateVolume called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("CreateVolume called with request %v", protosanitizer.StripSecrets(req))
 	cloud := cs.Driver.cloud
 
 	name := req.GetName()
@@ -190,7 +192,7 @@ func createVolumeValidation(name string, capacityRange *csi.CapacityRange) error
 
 func (cs *controllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolumeRequest) (
 	*csi.DeleteVolumeResponse, error) {
-	log.Infof("DeleteVolume called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("DeleteVolume called with request %v", protosanitizer.StripSecrets(req))
 	cloud := cs.Driver.cloud
 
 	volumeID := req.GetVolumeId()
@@ -207,7 +209,7 @@ func (cs *controllerServer) DeleteVolume(_ context.Context, req *csi.DeleteVolum
 
 func (cs *controllerServer) ControllerGetVolume(_ context.Context, req *csi.ControllerGetVolumeRequest) (
 	*csi.ControllerGetVolumeResponse, error) {
-	log.Infof("ControllerGetVolume called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ControllerGetVolume called with request %v", protosanitizer.StripSecrets(req))
 	cloud := cs.Driver.cloud
 	volumeID := req.GetVolumeId()
 	if len(volumeID) == 0 {
@@ -232,7 +234,7 @@ func (cs *controllerServer) ControllerGetVolume(_ context.Context, req *csi.Cont
 		},
 		Status: &csi.ControllerGetVolumeResponse_VolumeStatus{},
 	}
-	log.Infof("Successfully get volume detail: %v", protosanitizer.StripSecrets(response))
+	log.Infof("Successfully get volume detail: %v", protosanitizer.StripSecrets(&response))
 	return &response, nil
 }
 
@@ -248,7 +250,7 @@ func (cs *controllerServer) ControllerUnpublishVolume(_ context.Context, _ *csi.
 
 func (cs *controllerServer) ListVolumes(_ context.Context, req *csi.ListVolumesRequest) (
 	*csi.ListVolumesResponse, error) {
-	log.Infof("ListVolumes called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ListVolumes called with request %v", protosanitizer.StripSecrets(req))
 	cloud := cs.Driver.cloud
 
 	if req.GetMaxEntries() == 0 {
@@ -308,7 +310,7 @@ func (cs *controllerServer) ListSnapshots(_ context.Context, _ *csi.ListSnapshot
 
 func (cs *controllerServer) ControllerGetCapabilities(_ context.Context, req *csi.ControllerGetCapabilitiesRequest) (
 	*csi.ControllerGetCapabilitiesResponse, error) {
-	log.Infof("ControllerGetCapabilities called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ControllerGetCapabilities called with request %v", protosanitizer.StripSecrets(req))
 	return &csi.ControllerGetCapabilitiesResponse{
 		Capabilities: cs.Driver.cscap,
 	}, nil
@@ -316,7 +318,7 @@ func (cs *controllerServer) ControllerGetCapabilities(_ context.Context, req *cs
 
 func (cs *controllerServer) ValidateVolumeCapabilities(_ context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (
 	*csi.ValidateVolumeCapabilitiesResponse, error) {
-	log.Infof("ValidateVolumeCapabilities called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ValidateVolumeCapabilities called with request %v", protosanitizer.StripSecrets(req))
 
 	reqVolCap := req.GetVolumeCapabilities()
 	if len(reqVolCap) == 0 {
@@ -364,7 +366,7 @@ func (cs *controllerServer) GetCapacity(_ context.Context, _ *csi.GetCapacityReq
 
 func (cs *controllerServer) ControllerExpandVolume(_ context.Context, req *csi.ControllerExpandVolumeRequest) (
 	*csi.ControllerExpandVolumeResponse, error) {
-	log.Infof("ControllerExpandVolume called with request %v", protosanitizer.StripSecrets(*req))
+	log.Infof("ControllerExpandVolume called with request %v", protosanitizer.StripSecrets(req))
 	volumeID := req.GetVolumeId()
 	capacityRange := req.GetCapacityRange()
 	if err := expandVolumeValidation(volumeID, capacityRange); err != nil {
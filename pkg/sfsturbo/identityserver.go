@@ -25,6 +25,8 @@ import (
 )
 
 type identityServer struct {
+	csi.UnimplementedIdentityServer
+
 	Driver *SfsTurboDriver
 }
 
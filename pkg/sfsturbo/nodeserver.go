@@ -36,6 +36,8 @@ import (
 )
 
 type nodeServer struct {
+	csi.UnimplementedNodeServer
+
 	Driver   *SfsTurboDriver
 	Mount    mounts.IMount
 	Metadata metadatas.IMetadata
@@ -43,19 +45,19 @@ type nodeServer struct {
 
 func (ns *nodeServer) NodeStageVolume(_ context.Context, req *csi.NodeStageVolumeRequest) (
 	*csi.NodeStageVolumeResponse, error) {
-	log.Infof("NodeStageVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeStageVolume: called with args %v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
 func (ns *nodeServer) NodeUnstageVolume(_ context.Context, req *csi.NodeUnstageVolumeRequest) (
 	*csi.NodeUnstageVolumeResponse, error) {
-	log.Infof("NodeStageVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeStageVolume: called with args %v", protosanitizer.StripSecrets(req))
 	return nil, status.Error(codes.Unimplemented, "")
 }
 
 func (ns *nodeServer) NodePublishVolume(_ context.Context, req *csi.NodePublishVolumeRequest) (
 	*csi.NodePublishVolumeResponse, error) {
-	log.Infof("NodePublishVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodePublishVolume: called with args %v", protosanitizer.StripSecrets(req))
 	capability := req.GetVolumeCapability()
 	volumeID := req.GetVolumeId()
 	targetPath := req.GetTargetPath()
@@ -119,7 +121,7 @@ func nodePublishValidation(capability *csi.VolumeCapability, volumeID string, ta
 
 func (ns *nodeServer) NodeUnpublishVolume(_ context.Context, req *csi.NodeUnpublishVolumeRequest) (
 	*csi.NodeUnpublishVolumeResponse, error) {
-	log.Infof("NodeUnpublishVolume: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeUnpublishVolume: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	targetPath := req.GetTargetPath()
@@ -148,7 +150,7 @@ func (ns *nodeServer) NodeUnpublishVolume(_ context.Context, req *csi.NodeUnpubl
 }
 
 func (ns *nodeServer) NodeGetInfo(_ context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
-	log.Infof("NodeGetInfo: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetInfo: called with args %v", protosanitizer.StripSecrets(req))
 
 	idc := ns.Driver.cloud.Global.Idc
 	if idc {
@@ -174,7 +176,7 @@ func (ns *nodeServer) NodeGetInfo(_ context.Context, req *csi.NodeGetInfoRequest
 		return nil, status.Errorf(codes.Internal, "Unable to retrieve availability zone of node %v", err)
 	}
 	topology := &csi.Topology{Segments: map[string]string{topologyKey: zone}}
-	log.Infof("NodeGetInfo nodeID: %s, topology: %s", nodeID, protosanitizer.StripSecrets(*topology))
+	log.Infof("NodeGetInfo nodeID: %s, topology: %s", nodeID, protosanitizer.StripSecrets(topology))
 	return &csi.NodeGetInfoResponse{
 		NodeId:             nodeID,
 		AccessibleTopology: topology,
@@ -183,7 +185,7 @@ func (ns *nodeServer) NodeGetInfo(_ context.Context, req *csi.NodeGetInfoRequest
 
 func (ns *nodeServer) NodeGetCapabilities(_ context.Context, req *csi.NodeGetCapabilitiesRequest) (
 	*csi.NodeGetCapabilitiesResponse, error) {
-	log.Infof("NodeGetCapabilities: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetCapabilities: called with args %v", protosanitizer.StripSecrets(req))
 	return &csi.NodeGetCapabilitiesResponse{
 		Capabilities: ns.Driver.nscap,
 	}, nil
@@ -191,7 +193,7 @@ func (ns *nodeServer) NodeGetCapabilities(_ context.Context, req *csi.NodeGetCap
 
 func (ns *nodeServer) NodeGetVolumeStats(_ context.Context, req *csi.NodeGetVolumeStatsRequest) (
 	*csi.NodeGetVolumeStatsResponse, error) {
-	log.Infof("NodeGetVolumeStats: called with args %v", protosanitizer.StripSecrets(*req))
+	log.Infof("NodeGetVolumeStats: called with args %v", protosanitizer.StripSecrets(req))
 
 	volumeID := req.GetVolumeId()
 	volumePath := req.GetVolumePath()